@@ -0,0 +1,296 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+// pcidb is a command-line tool for managing the pcidb cache of pci.ids
+// database files.
+//
+// Usage:
+//
+//	pcidb compile      [-o FILE]
+//	pcidb write        [-o FILE] [-vendors IDS] [-classes IDS] [-no-subsystems]
+//	pcidb tables       [-o DIR] [-tsv] [-no-header]
+//	pcidb diff         [-json] OLD NEW
+//	pcidb cache list   [-system] [-path PATH]
+//	pcidb cache verify [-system] [-path PATH]
+//	pcidb cache prune  [-system] [-path PATH] [-max-age DURATION]
+//	pcidb cache fetch  [-system] [-path PATH] [-compress] [-retention N]
+//	pcidb cache pin    [-system] [-path PATH] [-retention N] FILE
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jaypipes/pcidb"
+)
+
+const usage = `usage: pcidb cache <list|verify|prune|fetch|pin> [flags]
+       pcidb compile [-o FILE]
+       pcidb write [-o FILE] [-vendors IDS] [-classes IDS] [-no-subsystems]
+       pcidb tables [-o DIR] [-tsv] [-no-header]
+       pcidb diff [-json] OLD NEW`
+
+func main() {
+	commands := map[string]func([]string) error{
+		"compile": compile,
+		"write":   write,
+		"tables":  tables,
+		"diff":    diff,
+	}
+	if len(os.Args) >= 2 && commands[os.Args[1]] != nil {
+		if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) < 3 || os.Args[1] != "cache" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd := os.Args[2]
+	fs := flag.NewFlagSet("cache "+cmd, flag.ExitOnError)
+	system := fs.Bool("system", false, "use the shared, system-wide cache")
+	path := fs.String("path", "", "path to the cache file")
+	compress := fs.Bool("compress", false, "store fetched files gzip-compressed")
+	maxAge := fs.Duration("max-age", 0, "prune cached files older than this")
+	retention := fs.Int("retention", 0, "number of cached versions to keep")
+	fs.Parse(os.Args[3:])
+
+	opts := []*pcidb.WithOption{}
+	if *system {
+		opts = append(opts, pcidb.WithSystemCache())
+	}
+	if *path != "" {
+		opts = append(opts, pcidb.WithCachePath(*path))
+	}
+	if *compress {
+		opts = append(opts, pcidb.WithCacheCompress())
+	}
+	if *retention > 0 {
+		opts = append(opts, pcidb.WithCacheRetention(*retention))
+	}
+	cache := pcidb.NewCache(opts...)
+
+	var err error
+	switch cmd {
+	case "list":
+		err = list(cache)
+	case "verify":
+		err = verify(cache)
+	case "prune":
+		err = prune(cache, *maxAge)
+	case "fetch":
+		err = fetch(cache)
+	case "pin":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = pin(cache, fs.Arg(0))
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func list(cache *pcidb.Cache) error {
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVERSION\tDATE\tCHECKSUM\tSIZE\tMODIFIED")
+	for _, e := range entries {
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%.12s\t%d\t%s\n",
+			e.Path, e.Version, e.Date, e.Checksum, e.Size,
+			e.ModTime.Format(time.RFC3339),
+		)
+	}
+	return w.Flush()
+}
+
+func verify(cache *pcidb.Cache) error {
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, e := range entries {
+		if err := cache.Verify(e); err != nil {
+			fmt.Printf("%s: FAILED (%v)\n", e.Path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: OK\n", e.Path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d cached file(s) failed verification", failed)
+	}
+	return nil
+}
+
+func prune(cache *pcidb.Cache, maxAge time.Duration) error {
+	removed, err := cache.Prune(maxAge)
+	for _, e := range removed {
+		fmt.Printf("removed %s\n", e.Path)
+	}
+	return err
+}
+
+func fetch(cache *pcidb.Cache) error {
+	e, err := cache.Fetch()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("fetched %s (version %s)\n", e.Path, e.Version)
+	return nil
+}
+
+func pin(cache *pcidb.Cache, path string) error {
+	e, err := cache.Pin(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pinned %s (version %s, sha256 %s)\n", e.Path, e.Version, e.Checksum)
+	return nil
+}
+
+// compile writes the compiled form of the discovered pci.ids database file to
+// the supplied output file or, by default, stores the compiled form of the
+// pcidb cache file where loading the cache file uses it
+func compile(args []string) error {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	out := fs.String("o", "", "output file (default the cache's compiled path)")
+	fs.Parse(args)
+
+	if *out == "" {
+		c := pcidb.NewCache()
+		src, err := c.Compile()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("compiled %s to %s\n", src, c.CompiledPath())
+		return nil
+	}
+	db, err := pcidb.New()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := pcidb.WriteCompiled(f, db, pcidb.CompiledSource{}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("compiled %d vendors to %s\n", len(db.Vendors), *out)
+	return nil
+}
+
+// write writes the discovered pci.ids database file in canonical form, keeping
+// only the requested vendors and classes, by default to standard output
+func write(args []string) error {
+	fs := flag.NewFlagSet("write", flag.ExitOnError)
+	out := fs.String("o", "", "output file (default standard output)")
+	vendors := fs.String("vendors", "", "comma-separated vendor IDs to keep")
+	classes := fs.String("classes", "", "comma-separated class IDs to keep")
+	noSubsystems := fs.Bool("no-subsystems", false, "leave out product subsystems")
+	fs.Parse(args)
+
+	opts := []*pcidb.WithOption{}
+	if *vendors != "" {
+		opts = append(opts, pcidb.WithVendors(strings.Split(*vendors, ",")...))
+	}
+	if *classes != "" {
+		opts = append(opts, pcidb.WithClasses(strings.Split(*classes, ",")...))
+	}
+	if *noSubsystems {
+		opts = append(opts, pcidb.WithoutSubsystems())
+	}
+	db, err := pcidb.New(opts...)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return pcidb.WriteText(os.Stdout, db, pcidb.TextHeader{})
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := pcidb.WriteText(f, db, pcidb.TextHeader{}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// tables writes the discovered pci.ids database file as CSV or TSV tables of
+// vendors, products, subsystems and classes
+func tables(args []string) error {
+	fs := flag.NewFlagSet("tables", flag.ExitOnError)
+	out := fs.String("o", ".", "output directory")
+	tsv := fs.Bool("tsv", false, "write tab-separated instead of comma-separated tables")
+	noHeader := fs.Bool("no-header", false, "leave out the header row of column names")
+	fs.Parse(args)
+
+	db, err := pcidb.New()
+	if err != nil {
+		return err
+	}
+	opts := pcidb.TableOptions{NoHeader: *noHeader}
+	if *tsv {
+		opts.Delimiter = '\t'
+	}
+	if err := pcidb.WriteTables(*out, db, opts); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d vendors to %s\n", len(db.Vendors), *out)
+	return nil
+}
+
+// diff prints the differences between two pci.ids database files
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the differences as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var dbs [2]*pcidb.PCIDB
+	for x, path := range fs.Args() {
+		db, err := pcidb.New(pcidb.WithPath(path), pcidb.WithDisableMemoization())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		dbs[x] = db
+	}
+	d := pcidb.Diff(dbs[0], dbs[1])
+	if *asJSON {
+		return d.WriteJSON(os.Stdout)
+	}
+	return d.WriteText(os.Stdout)
+}
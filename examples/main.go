@@ -3,6 +3,7 @@ package main
 import (
 	"cmp"
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
 
@@ -28,15 +29,15 @@ func (v ByCountSeparateSubvendors) Less(i, j int) bool {
 	jNumDiffSubvendors := 0
 
 	for _, sub := range v[i].Subsystems {
-		if sub.VendorID != iVendor {
-			iSetSubvendors[sub.VendorID] = true
+		if sub.SubvendorID != iVendor {
+			iSetSubvendors[sub.SubvendorID] = true
 		}
 	}
 	iNumDiffSubvendors = len(iSetSubvendors)
 
 	for _, sub := range v[j].Subsystems {
-		if sub.VendorID != jVendor {
-			jSetSubvendors[sub.VendorID] = true
+		if sub.SubvendorID != jVendor {
+			jSetSubvendors[sub.SubvendorID] = true
 		}
 	}
 	jNumDiffSubvendors = len(jSetSubvendors)
@@ -50,7 +51,7 @@ func main() {
 		fmt.Printf("Error getting PCI info: %v", err)
 	}
 
-	for _, devClass := range pci.Classes {
+	for devClass := range pci.AllClasses() {
 		fmt.Printf(" Device class: %v ('%v')\n", devClass.Name, devClass.ID)
 		for _, devSubclass := range devClass.Subclasses {
 			fmt.Printf("    Device subclass: %v ('%v')\n", devSubclass.Name, devSubclass.ID)
@@ -60,17 +61,12 @@ func main() {
 		}
 	}
 
-	vendors := make([]*pcidb.Vendor, len(pci.Vendors))
-	x := 0
-	for _, vendor := range pci.Vendors {
-		vendors[x] = vendor
-		x++
-	}
-
-	slices.SortFunc(vendors, func(a, b *pcidb.Vendor) int {
-		return cmp.Compare(len(a.Products), len(b.Products))
+	// Vendors and products are collected in ID order and sorted stably, so
+	// that ties are always listed in the same order
+	vendors := slices.Collect(pci.AllVendors())
+	slices.SortStableFunc(vendors, func(a, b *pcidb.Vendor) int {
+		return cmp.Compare(len(b.Products), len(a.Products))
 	})
-	slices.Reverse(vendors)
 
 	fmt.Println("Top 5 vendors by product")
 	fmt.Println("====================================================")
@@ -78,14 +74,8 @@ func main() {
 		fmt.Printf("%v ('%v') has %d products\n", vendor.Name, vendor.ID, len(vendor.Products))
 	}
 
-	products := make([]*pcidb.Product, len(pci.Products))
-	x = 0
-	for _, product := range pci.Products {
-		products[x] = product
-		x++
-	}
-
-	sort.Sort(ByCountSeparateSubvendors(products))
+	products := slices.Collect(pci.AllProducts())
+	sort.Stable(ByCountSeparateSubvendors(products))
 
 	fmt.Println("Top 2 products by # different subvendors")
 	fmt.Println("====================================================")
@@ -95,13 +85,13 @@ func main() {
 		setSubvendors := make(map[string]bool, 0)
 
 		for _, sub := range product.Subsystems {
-			if sub.VendorID != vendorID {
-				setSubvendors[sub.VendorID] = true
+			if sub.SubvendorID != vendorID {
+				setSubvendors[sub.SubvendorID] = true
 			}
 		}
 		fmt.Printf("%v ('%v') from %v\n", product.Name, product.ID, vendor.Name)
 		fmt.Printf(" -> %d subsystems under the following different vendors:\n", len(setSubvendors))
-		for subvendorID, _ := range setSubvendors {
+		for _, subvendorID := range slices.Sorted(maps.Keys(setSubvendors)) {
 			subvendor, exists := pci.Vendors[subvendorID]
 			subvendorName := "Unknown subvendor"
 			if exists {
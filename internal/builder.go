@@ -0,0 +1,626 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// Builder constructs a DB entry by entry, for example for tests or to
+// generate a custom pci-ids DB file with WriteText. Each Add method validates
+// the IDs it is given, rejects an entry that already exists or whose parent
+// doesn't, and updates the DB's flat maps, numeric IDs and the pointers
+// between entries, so that the DB is consistent after every call. The Remove
+// and Rename methods remove and rename existing entries just as consistently,
+// and reject an entry that doesn't exist.
+//
+// IDs are hex-encoded, with exactly 4 digits for vendor, product, subvendor
+// and subdevice IDs and 2 digits for class, subclass and programming
+// interface IDs, in either case. They are stored in lowercase.
+type Builder struct {
+	db *types.DB
+}
+
+// NewBuilder returns a Builder for an empty DB.
+func NewBuilder() *Builder {
+	return &Builder{db: &types.DB{
+		Classes:               map[string]*types.Class{},
+		Vendors:               map[string]*types.Vendor{},
+		Products:              map[string]*types.Product{},
+		Subclasses:            map[string]*types.Subclass{},
+		ProgrammingInterfaces: map[string]*types.ProgrammingInterface{},
+		Subsystems:            map[string]*types.Subsystem{},
+		SubvendorSubsystems:   map[string][]*types.Subsystem{},
+	}}
+}
+
+// NewBuilderFrom returns a Builder for a copy of the supplied DB, so that
+// entries can be added to a loaded DB without modifying it, since loaded DBs
+// may be shared with other callers.
+func NewBuilderFrom(db *types.DB) *Builder {
+	b := NewBuilder()
+	for id, v := range db.Vendors {
+		nv := *v
+		nv.Products = make([]*types.Product, len(v.Products))
+		for x, p := range v.Products {
+			np := *p
+			np.Vendor = &nv
+			np.Subsystems = make([]*types.Subsystem, len(p.Subsystems))
+			for y, s := range p.Subsystems {
+				ns := *s
+				ns.Product = &np
+				np.Subsystems[y] = &ns
+			}
+			nv.Products[x] = &np
+		}
+		b.db.Vendors[id] = &nv
+	}
+	for id, c := range db.Classes {
+		nc := *c
+		nc.Subclasses = make([]*types.Subclass, len(c.Subclasses))
+		for x, sc := range c.Subclasses {
+			nsc := *sc
+			nsc.Class = &nc
+			nsc.ProgrammingInterfaces = make([]*types.ProgrammingInterface, len(sc.ProgrammingInterfaces))
+			for y, pi := range sc.ProgrammingInterfaces {
+				npi := *pi
+				npi.Subclass = &nsc
+				nsc.ProgrammingInterfaces[y] = &npi
+			}
+			nc.Subclasses[x] = &nsc
+		}
+		b.db.Classes[id] = &nc
+	}
+	if db.Layers != nil {
+		b.db.Layers = append([]string{}, db.Layers...)
+	}
+	indexDB(b.db)
+	for _, s := range b.db.Subsystems {
+		s.Subvendor = b.db.Vendors[s.SubvendorID]
+	}
+	return b
+}
+
+// DB returns the DB being built. Entries added afterwards are added to the
+// same DB, so it must not be shared until all of them have been added.
+func (b *Builder) DB() *types.DB {
+	return b.db
+}
+
+// AddVendor adds a vendor with the supplied ID and name and returns it. Any
+// subsystems already added with the vendor as their subvendor are linked to
+// it.
+func (b *Builder) AddVendor(id string, name string) (*types.Vendor, error) {
+	vid, err := normalizeID(id, 4)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := b.db.Vendors[vid]; exists {
+		return nil, fmt.Errorf("%w: vendor %q", types.ErrDuplicateEntry, vid)
+	}
+	if err := checkName("vendor", vid, name); err != nil {
+		return nil, err
+	}
+	v := &types.Vendor{
+		ID:        vid,
+		NumericID: types.VendorID(hexValue(vid)),
+		Name:      name,
+		Products:  []*types.Product{},
+	}
+	b.db.Vendors[vid] = v
+	for _, s := range b.db.SubvendorSubsystems[vid] {
+		s.Subvendor = v
+	}
+	return v, nil
+}
+
+// AddProduct adds a product with the supplied ID and name to the vendor with
+// the supplied ID and returns it. The vendor's products are kept sorted by
+// ID.
+func (b *Builder) AddProduct(
+	vendorID string,
+	productID string,
+	name string,
+) (*types.Product, error) {
+	vid, err := normalizeID(vendorID, 4)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := normalizeID(productID, 4)
+	if err != nil {
+		return nil, err
+	}
+	v := b.db.Vendors[vid]
+	if v == nil {
+		return nil, fmt.Errorf("%w: vendor %q", types.ErrMissingParent, vid)
+	}
+	if _, exists := b.db.Products[vid+pid]; exists {
+		return nil, fmt.Errorf("%w: product %q", types.ErrDuplicateEntry, vid+pid)
+	}
+	if err := checkName("product", vid+pid, name); err != nil {
+		return nil, err
+	}
+	p := &types.Product{
+		VendorID:        vid,
+		NumericVendorID: v.NumericID,
+		Vendor:          v,
+		ID:              pid,
+		NumericID:       types.DeviceID(hexValue(pid)),
+		Name:            name,
+		Subsystems:      []*types.Subsystem{},
+	}
+	x := sort.Search(len(v.Products), func(x int) bool { return v.Products[x].ID > pid })
+	v.Products = insertAt(v.Products, x, p)
+	b.db.Products[vid+pid] = p
+	return p, nil
+}
+
+// AddSubsystem adds a subsystem with the supplied subvendor and subdevice IDs
+// and name to the product with the supplied vendor and product IDs and
+// returns it. The product's subsystems are kept in the order they are added,
+// as they are listed in a pci-ids DB file. The subvendor doesn't need to be
+// in the DB; the subsystem is linked to it if it is, or when it is added.
+func (b *Builder) AddSubsystem(
+	vendorID string,
+	productID string,
+	subvendorID string,
+	subdeviceID string,
+	name string,
+) (*types.Subsystem, error) {
+	ids, err := normalizeIDs(4, vendorID, productID, subvendorID, subdeviceID)
+	if err != nil {
+		return nil, err
+	}
+	vid, pid, svid, sdid := ids[0], ids[1], ids[2], ids[3]
+	p := b.db.Products[vid+pid]
+	if p == nil {
+		return nil, fmt.Errorf("%w: product %q", types.ErrMissingParent, vid+pid)
+	}
+	key := vid + pid + svid + sdid
+	if _, exists := b.db.Subsystems[key]; exists {
+		return nil, fmt.Errorf("%w: subsystem %q", types.ErrDuplicateEntry, key)
+	}
+	if err := checkName("subsystem", key, name); err != nil {
+		return nil, err
+	}
+	s := &types.Subsystem{
+		SubvendorID:        svid,
+		NumericSubvendorID: types.VendorID(hexValue(svid)),
+		SubdeviceID:        sdid,
+		NumericSubdeviceID: types.DeviceID(hexValue(sdid)),
+		Name:               name,
+		Product:            p,
+		Subvendor:          b.db.Vendors[svid],
+		VendorID:           svid,
+		ID:                 sdid,
+	}
+	p.Subsystems = append(p.Subsystems, s)
+	b.db.Subsystems[key] = s
+	// Subvendor index groups are ordered as the subsystems appear in a
+	// pci-ids DB file: by vendor and product ID, then in the order listed
+	group := b.db.SubvendorSubsystems[svid]
+	x := sort.Search(len(group), func(x int) bool {
+		g := group[x].Product
+		return g.VendorID+g.ID > vid+pid
+	})
+	b.db.SubvendorSubsystems[svid] = insertAt(group, x, s)
+	return s, nil
+}
+
+// AddClass adds a class with the supplied ID and name and returns it.
+func (b *Builder) AddClass(id string, name string) (*types.Class, error) {
+	cid, err := normalizeID(id, 2)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := b.db.Classes[cid]; exists {
+		return nil, fmt.Errorf("%w: class %q", types.ErrDuplicateEntry, cid)
+	}
+	if err := checkName("class", cid, name); err != nil {
+		return nil, err
+	}
+	c := &types.Class{
+		ID:         cid,
+		NumericID:  types.ClassID(hexValue(cid)),
+		Name:       name,
+		Subclasses: []*types.Subclass{},
+	}
+	b.db.Classes[cid] = c
+	return c, nil
+}
+
+// AddSubclass adds a subclass with the supplied ID and name to the class with
+// the supplied ID and returns it. The class's subclasses are kept sorted by
+// ID.
+func (b *Builder) AddSubclass(
+	classID string,
+	subclassID string,
+	name string,
+) (*types.Subclass, error) {
+	ids, err := normalizeIDs(2, classID, subclassID)
+	if err != nil {
+		return nil, err
+	}
+	cid, scid := ids[0], ids[1]
+	c := b.db.Classes[cid]
+	if c == nil {
+		return nil, fmt.Errorf("%w: class %q", types.ErrMissingParent, cid)
+	}
+	if _, exists := b.db.Subclasses[cid+scid]; exists {
+		return nil, fmt.Errorf("%w: subclass %q", types.ErrDuplicateEntry, cid+scid)
+	}
+	if err := checkName("subclass", cid+scid, name); err != nil {
+		return nil, err
+	}
+	sc := &types.Subclass{
+		ID:                    scid,
+		Name:                  name,
+		Class:                 c,
+		ProgrammingInterfaces: []*types.ProgrammingInterface{},
+	}
+	x := sort.Search(len(c.Subclasses), func(x int) bool { return c.Subclasses[x].ID > scid })
+	c.Subclasses = insertAt(c.Subclasses, x, sc)
+	b.db.Subclasses[cid+scid] = sc
+	return sc, nil
+}
+
+// AddProgIf adds a programming interface with the supplied ID and name to the
+// subclass with the supplied class and subclass IDs and returns it. The
+// subclass's programming interfaces are kept sorted by ID.
+func (b *Builder) AddProgIf(
+	classID string,
+	subclassID string,
+	progIfID string,
+	name string,
+) (*types.ProgrammingInterface, error) {
+	ids, err := normalizeIDs(2, classID, subclassID, progIfID)
+	if err != nil {
+		return nil, err
+	}
+	cid, scid, piid := ids[0], ids[1], ids[2]
+	sc := b.db.Subclasses[cid+scid]
+	if sc == nil {
+		return nil, fmt.Errorf("%w: subclass %q", types.ErrMissingParent, cid+scid)
+	}
+	key := cid + scid + piid
+	if _, exists := b.db.ProgrammingInterfaces[key]; exists {
+		return nil, fmt.Errorf("%w: programming interface %q", types.ErrDuplicateEntry, key)
+	}
+	if err := checkName("programming interface", key, name); err != nil {
+		return nil, err
+	}
+	pi := &types.ProgrammingInterface{ID: piid, Name: name, Subclass: sc}
+	x := sort.Search(len(sc.ProgrammingInterfaces), func(x int) bool {
+		return sc.ProgrammingInterfaces[x].ID > piid
+	})
+	sc.ProgrammingInterfaces = insertAt(sc.ProgrammingInterfaces, x, pi)
+	b.db.ProgrammingInterfaces[key] = pi
+	return pi, nil
+}
+
+// RemoveVendor removes the vendor with the supplied ID, along with its
+// products and their subsystems. Subsystems of other vendors' products that
+// have the vendor as their subvendor are kept, but are no longer linked to
+// it.
+func (b *Builder) RemoveVendor(id string) error {
+	vid, err := normalizeID(id, 4)
+	if err != nil {
+		return err
+	}
+	v := b.db.Vendors[vid]
+	if v == nil {
+		return fmt.Errorf("%w: vendor %q", types.ErrEntryNotFound, vid)
+	}
+	for _, p := range v.Products {
+		b.unindexProduct(p)
+	}
+	delete(b.db.Vendors, vid)
+	for _, s := range b.db.SubvendorSubsystems[vid] {
+		s.Subvendor = nil
+	}
+	return nil
+}
+
+// RemoveProduct removes the product with the supplied vendor and product IDs,
+// along with its subsystems.
+func (b *Builder) RemoveProduct(vendorID string, productID string) error {
+	ids, err := normalizeIDs(4, vendorID, productID)
+	if err != nil {
+		return err
+	}
+	p := b.db.Products[ids[0]+ids[1]]
+	if p == nil {
+		return fmt.Errorf("%w: product %q", types.ErrEntryNotFound, ids[0]+ids[1])
+	}
+	p.Vendor.Products = removeEntry(p.Vendor.Products, p)
+	b.unindexProduct(p)
+	return nil
+}
+
+// RemoveSubsystem removes the subsystem with the supplied subvendor and
+// subdevice IDs from the product with the supplied vendor and product IDs.
+func (b *Builder) RemoveSubsystem(
+	vendorID string,
+	productID string,
+	subvendorID string,
+	subdeviceID string,
+) error {
+	ids, err := normalizeIDs(4, vendorID, productID, subvendorID, subdeviceID)
+	if err != nil {
+		return err
+	}
+	key := ids[0] + ids[1] + ids[2] + ids[3]
+	s := b.db.Subsystems[key]
+	if s == nil {
+		return fmt.Errorf("%w: subsystem %q", types.ErrEntryNotFound, key)
+	}
+	s.Product.Subsystems = removeEntry(s.Product.Subsystems, s)
+	b.unindexSubsystem(key, s)
+	return nil
+}
+
+// RemoveClass removes the class with the supplied ID, along with its
+// subclasses and their programming interfaces.
+func (b *Builder) RemoveClass(id string) error {
+	cid, err := normalizeID(id, 2)
+	if err != nil {
+		return err
+	}
+	c := b.db.Classes[cid]
+	if c == nil {
+		return fmt.Errorf("%w: class %q", types.ErrEntryNotFound, cid)
+	}
+	for _, sc := range c.Subclasses {
+		b.unindexSubclass(cid, sc)
+	}
+	delete(b.db.Classes, cid)
+	return nil
+}
+
+// RemoveSubclass removes the subclass with the supplied class and subclass
+// IDs, along with its programming interfaces.
+func (b *Builder) RemoveSubclass(classID string, subclassID string) error {
+	ids, err := normalizeIDs(2, classID, subclassID)
+	if err != nil {
+		return err
+	}
+	sc := b.db.Subclasses[ids[0]+ids[1]]
+	if sc == nil {
+		return fmt.Errorf("%w: subclass %q", types.ErrEntryNotFound, ids[0]+ids[1])
+	}
+	sc.Class.Subclasses = removeEntry(sc.Class.Subclasses, sc)
+	b.unindexSubclass(ids[0], sc)
+	return nil
+}
+
+// RemoveProgIf removes the programming interface with the supplied ID from
+// the subclass with the supplied class and subclass IDs.
+func (b *Builder) RemoveProgIf(
+	classID string,
+	subclassID string,
+	progIfID string,
+) error {
+	ids, err := normalizeIDs(2, classID, subclassID, progIfID)
+	if err != nil {
+		return err
+	}
+	key := ids[0] + ids[1] + ids[2]
+	pi := b.db.ProgrammingInterfaces[key]
+	if pi == nil {
+		return fmt.Errorf("%w: programming interface %q", types.ErrEntryNotFound, key)
+	}
+	pi.Subclass.ProgrammingInterfaces = removeEntry(pi.Subclass.ProgrammingInterfaces, pi)
+	delete(b.db.ProgrammingInterfaces, key)
+	return nil
+}
+
+// RenameVendor changes the name of the vendor with the supplied ID.
+func (b *Builder) RenameVendor(id string, name string) error {
+	vid, err := normalizeID(id, 4)
+	if err != nil {
+		return err
+	}
+	v := b.db.Vendors[vid]
+	if v == nil {
+		return fmt.Errorf("%w: vendor %q", types.ErrEntryNotFound, vid)
+	}
+	return setName(&v.Name, "vendor", vid, name)
+}
+
+// RenameProduct changes the name of the product with the supplied vendor and
+// product IDs.
+func (b *Builder) RenameProduct(
+	vendorID string,
+	productID string,
+	name string,
+) error {
+	ids, err := normalizeIDs(4, vendorID, productID)
+	if err != nil {
+		return err
+	}
+	key := ids[0] + ids[1]
+	p := b.db.Products[key]
+	if p == nil {
+		return fmt.Errorf("%w: product %q", types.ErrEntryNotFound, key)
+	}
+	return setName(&p.Name, "product", key, name)
+}
+
+// RenameSubsystem changes the name of the subsystem with the supplied
+// subvendor and subdevice IDs of the product with the supplied vendor and
+// product IDs.
+func (b *Builder) RenameSubsystem(
+	vendorID string,
+	productID string,
+	subvendorID string,
+	subdeviceID string,
+	name string,
+) error {
+	ids, err := normalizeIDs(4, vendorID, productID, subvendorID, subdeviceID)
+	if err != nil {
+		return err
+	}
+	key := ids[0] + ids[1] + ids[2] + ids[3]
+	s := b.db.Subsystems[key]
+	if s == nil {
+		return fmt.Errorf("%w: subsystem %q", types.ErrEntryNotFound, key)
+	}
+	return setName(&s.Name, "subsystem", key, name)
+}
+
+// RenameClass changes the name of the class with the supplied ID.
+func (b *Builder) RenameClass(id string, name string) error {
+	cid, err := normalizeID(id, 2)
+	if err != nil {
+		return err
+	}
+	c := b.db.Classes[cid]
+	if c == nil {
+		return fmt.Errorf("%w: class %q", types.ErrEntryNotFound, cid)
+	}
+	return setName(&c.Name, "class", cid, name)
+}
+
+// RenameSubclass changes the name of the subclass with the supplied class
+// and subclass IDs.
+func (b *Builder) RenameSubclass(
+	classID string,
+	subclassID string,
+	name string,
+) error {
+	ids, err := normalizeIDs(2, classID, subclassID)
+	if err != nil {
+		return err
+	}
+	key := ids[0] + ids[1]
+	sc := b.db.Subclasses[key]
+	if sc == nil {
+		return fmt.Errorf("%w: subclass %q", types.ErrEntryNotFound, key)
+	}
+	return setName(&sc.Name, "subclass", key, name)
+}
+
+// RenameProgIf changes the name of the programming interface with the
+// supplied ID of the subclass with the supplied class and subclass IDs.
+func (b *Builder) RenameProgIf(
+	classID string,
+	subclassID string,
+	progIfID string,
+	name string,
+) error {
+	ids, err := normalizeIDs(2, classID, subclassID, progIfID)
+	if err != nil {
+		return err
+	}
+	key := ids[0] + ids[1] + ids[2]
+	pi := b.db.ProgrammingInterfaces[key]
+	if pi == nil {
+		return fmt.Errorf("%w: programming interface %q", types.ErrEntryNotFound, key)
+	}
+	return setName(&pi.Name, "programming interface", key, name)
+}
+
+// unindexProduct removes the supplied product and its subsystems from the
+// flat maps and the subvendor index
+func (b *Builder) unindexProduct(p *types.Product) {
+	key := p.VendorID + p.ID
+	for _, s := range p.Subsystems {
+		b.unindexSubsystem(key+s.SubvendorID+s.SubdeviceID, s)
+	}
+	delete(b.db.Products, key)
+}
+
+// unindexSubsystem removes the supplied subsystem, with the supplied key,
+// from the flat map of subsystems and from the subvendor index, dropping its
+// subvendor's group when it is the last subsystem in it
+func (b *Builder) unindexSubsystem(key string, s *types.Subsystem) {
+	delete(b.db.Subsystems, key)
+	group := removeEntry(b.db.SubvendorSubsystems[s.SubvendorID], s)
+	if len(group) == 0 {
+		delete(b.db.SubvendorSubsystems, s.SubvendorID)
+		return
+	}
+	b.db.SubvendorSubsystems[s.SubvendorID] = group
+}
+
+// unindexSubclass removes the supplied subclass of the class with the
+// supplied ID, and its programming interfaces, from the flat maps
+func (b *Builder) unindexSubclass(classID string, sc *types.Subclass) {
+	key := classID + sc.ID
+	for _, pi := range sc.ProgrammingInterfaces {
+		delete(b.db.ProgrammingInterfaces, key+pi.ID)
+	}
+	delete(b.db.Subclasses, key)
+}
+
+// normalizeID returns the lowercase form of the supplied hex-encoded ID, or
+// an error wrapping types.ErrInvalidID if it is not the supplied number of
+// hex digits
+func normalizeID(id string, width int) (string, error) {
+	v, err := parseHexID(id, 4*width)
+	if err != nil || len(id) != width {
+		return "", fmt.Errorf("%w %q", types.ErrInvalidID, id)
+	}
+	return hexID(v, width), nil
+}
+
+// normalizeIDs returns the lowercase forms of the supplied hex-encoded IDs,
+// which must all have the supplied number of digits
+func normalizeIDs(width int, ids ...string) ([]string, error) {
+	normalized := make([]string, len(ids))
+	for x, id := range ids {
+		n, err := normalizeID(id, width)
+		if err != nil {
+			return nil, err
+		}
+		normalized[x] = n
+	}
+	return normalized, nil
+}
+
+// checkName returns an error wrapping types.ErrInvalidDB if the supplied name
+// of the entry with the supplied kind and key contains a line break, which
+// could not be written to a pci-ids DB file
+func checkName(kind string, key string, name string) error {
+	if strings.ContainsAny(name, "\r\n") {
+		return fmt.Errorf("%w: name of %s %q has a line break", types.ErrInvalidDB, kind, key)
+	}
+	return nil
+}
+
+// setName sets the supplied name of the entry with the supplied kind and key
+// to the supplied new name, if it passes checkName
+func setName(name *string, kind string, key string, newName string) error {
+	if err := checkName(kind, key, newName); err != nil {
+		return err
+	}
+	*name = newName
+	return nil
+}
+
+// removeEntry returns the supplied entries without the supplied entry
+func removeEntry[T comparable](entries []T, entry T) []T {
+	if x := slices.Index(entries, entry); x >= 0 {
+		return slices.Delete(entries, x, x+1)
+	}
+	return entries
+}
+
+// insertAt returns the supplied entries with the supplied entry inserted at
+// the supplied index
+func insertAt[T any](entries []T, x int, entry T) []T {
+	var zero T
+	entries = append(entries, zero)
+	copy(entries[x+1:], entries[x:])
+	entries[x] = entry
+	return entries
+}
@@ -0,0 +1,283 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	mustAdd := func(_ any, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Expected no error adding entry, but got %v", err)
+		}
+	}
+	mustAdd(b.AddVendor("8086", "Intel Corporation"))
+	mustAdd(b.AddProduct("8086", "1572", "Ethernet Controller X710 for 10GbE SFP+"))
+	mustAdd(b.AddProduct("8086", "10F8", "82599 10 Gigabit Dual Port Backplane Connection"))
+	// Dell is added after the subsystems it is the subvendor of
+	mustAdd(b.AddSubsystem("8086", "1572", "1028", "0000", "Ethernet 10G X710 rNDC"))
+	mustAdd(b.AddSubsystem("8086", "10f8", "1028", "1f63", "82599 10G Dual Port Backplane Connection"))
+	mustAdd(b.AddSubsystem("8086", "10f8", "8086", "000c", "Ethernet X520 10GbE Dual Port KX4-KR Mezz"))
+	mustAdd(b.AddVendor("1028", "Dell"))
+	mustAdd(b.AddClass("0c", "Serial bus controller"))
+	mustAdd(b.AddSubclass("0c", "03", "USB controller"))
+	mustAdd(b.AddProgIf("0c", "03", "30", "XHCI"))
+	mustAdd(b.AddProgIf("0c", "03", "00", "UHCI"))
+	db := b.DB()
+	checkIndexes(t, db)
+
+	intel := db.Vendors["8086"]
+	if len(intel.Products) != 2 || intel.Products[0].ID != "10f8" || intel.Products[1].ID != "1572" {
+		t.Fatalf("Expected products sorted by lowercase ID but got %+v", intel.Products)
+	}
+	p := db.ProductByID(0x8086, 0x10f8)
+	if p == nil || p.Vendor != intel || p.NumericVendorID != 0x8086 {
+		t.Fatalf("Expected product linked to its vendor but got %+v", p)
+	}
+	dell := db.Vendors["1028"]
+	s := db.Subsystems["8086157210280000"]
+	if s == nil || s.Subvendor != dell || s.Product != db.Products["80861572"] {
+		t.Fatalf("Expected subsystem linked to the later-added subvendor but got %+v", s)
+	}
+	var got []string
+	for _, s := range db.SubsystemsBySubvendor("1028") {
+		got = append(got, s.Product.ID+s.SubdeviceID)
+	}
+	if want := []string{"10f81f63", "15720000"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected subvendor subsystems in file order %q but got %q", want, got)
+	}
+	usb := db.Subclasses["0c03"]
+	if usb == nil || usb.Class != db.Classes["0c"] || db.ClassByID(0x0c).NumericID != 0x0c {
+		t.Fatalf("Expected subclass linked to its class but got %+v", usb)
+	}
+	if usb.ProgrammingInterfaces[0].ID != "00" || db.ProgrammingInterfaces["0c0330"].Subclass != usb {
+		t.Fatalf("Expected sorted, linked programming interfaces but got %+v", usb.ProgrammingInterfaces)
+	}
+
+	// The built DB is the same as the one parsed from its pci-ids DB file
+	var out bytes.Buffer
+	if err := WriteText(&out, db, TextHeader{}); err != nil {
+		t.Fatalf("Expected no error writing DB, but got %v", err)
+	}
+	parsed := parseText(out.String(), nil)
+	if d := types.Diff(parsed, db); len(d.Changes) != 0 {
+		t.Fatalf("Expected no differences from the parsed DB but got %+v", d.Changes)
+	}
+	for id, group := range parsed.SubvendorSubsystems {
+		for x, s := range group {
+			if built := db.SubvendorSubsystems[id][x]; built.Name != s.Name {
+				t.Fatalf("Expected the same subvendor index as the parsed DB, but got %q at %s[%d]",
+					built.Name, id, x)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		add  func() error
+		want error
+	}{
+		{"short vendor ID", func() error { _, err := b.AddVendor("808", "x"); return err }, types.ErrInvalidID},
+		{"non-hex class ID", func() error { _, err := b.AddClass("0g", "x"); return err }, types.ErrInvalidID},
+		{"prefixed product ID", func() error { _, err := b.AddProduct("8086", "0x10", "x"); return err }, types.ErrInvalidID},
+		{"duplicate vendor", func() error { _, err := b.AddVendor("8086", "x"); return err }, types.ErrDuplicateEntry},
+		{"duplicate product", func() error { _, err := b.AddProduct("8086", "10f8", "x"); return err }, types.ErrDuplicateEntry},
+		{
+			"duplicate subsystem",
+			func() error { _, err := b.AddSubsystem("8086", "10F8", "8086", "000C", "x"); return err },
+			types.ErrDuplicateEntry,
+		},
+		{"duplicate programming interface", func() error { _, err := b.AddProgIf("0c", "03", "30", "x"); return err }, types.ErrDuplicateEntry},
+		{"missing vendor", func() error { _, err := b.AddProduct("10de", "2330", "x"); return err }, types.ErrMissingParent},
+		{
+			"missing product",
+			func() error { _, err := b.AddSubsystem("8086", "0001", "8086", "0001", "x"); return err },
+			types.ErrMissingParent,
+		},
+		{"missing class", func() error { _, err := b.AddSubclass("02", "00", "x"); return err }, types.ErrMissingParent},
+		{"missing subclass", func() error { _, err := b.AddProgIf("0c", "00", "00", "x"); return err }, types.ErrMissingParent},
+		{"line break", func() error { _, err := b.AddVendor("10de", "NVIDIA\nCorporation"); return err }, types.ErrInvalidDB},
+	}
+	for _, test := range tests {
+		if err := test.add(); !errors.Is(err, test.want) {
+			t.Fatalf("Expected %s to fail with %v but got %v", test.name, test.want, err)
+		}
+	}
+	if len(db.Vendors) != 2 || len(db.Products) != 2 || len(db.Subsystems) != 3 {
+		t.Fatalf("Expected rejected entries not to be added")
+	}
+}
+
+func TestBuilderFrom(t *testing.T) {
+	f, err := os.Open(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error opening fixture, but got %v", err)
+	}
+	base := FromReader(f)
+	products := len(base.Products)
+
+	b := NewBuilderFrom(base)
+	if d := types.Diff(base, b.DB()); len(d.Changes) != 0 {
+		t.Fatalf("Expected a copy of the DB but got changes %+v", d.Changes)
+	}
+	if _, err := b.AddProduct("8086", "0b60", "Internal FPGA Accelerator"); err != nil {
+		t.Fatalf("Expected no error adding product, but got %v", err)
+	}
+	if _, err := b.AddSubsystem("8086", "0b60", "1590", "0001", "FPGA Accelerator Rev A"); err != nil {
+		t.Fatalf("Expected no error adding subsystem, but got %v", err)
+	}
+	if len(base.Products) != products || len(base.Vendors["8086"].Products) != 2 {
+		t.Fatalf("Expected the original DB to be unmodified")
+	}
+
+	db := b.DB()
+	intel := db.Vendors["8086"]
+	if intel == base.Vendors["8086"] || intel.Products[0].ID != "0b60" {
+		t.Fatalf("Expected product added to a copy of the vendor, in ID order")
+	}
+	for _, p := range intel.Products {
+		if p.Vendor != intel {
+			t.Fatalf("Expected copied product %s to belong to the copied vendor", p.ID)
+		}
+	}
+	hpe := db.SubsystemsBySubvendor("1590")
+	if len(hpe) != 2 || hpe[0].Product.ID != "101b" || hpe[1].Subvendor != db.Vendors["1590"] {
+		t.Fatalf("Expected subvendor index with the added subsystem but got %+v", hpe)
+	}
+	if db.Subclasses["0c03"].Class != db.Classes["0c"] {
+		t.Fatalf("Expected copied subclass to belong to the copied class")
+	}
+}
+
+func TestBuilderRemoveRename(t *testing.T) {
+	f, err := os.Open(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error opening fixture, but got %v", err)
+	}
+	base := FromReader(f)
+	products := len(base.Products)
+
+	b := NewBuilderFrom(base)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+	}
+	must(b.RemoveSubsystem("101E", "1960", "1028", "0471"))
+	must(b.RemoveVendor("103c"))
+	must(b.RemoveProduct("15b3", "101b"))
+	must(b.RemoveProgIf("0c", "03", "00"))
+	must(b.RemoveSubclass("01", "08"))
+	must(b.RemoveClass("02"))
+	must(b.RenameVendor("8086", "Intel"))
+	must(b.RenameProduct("8086", "10F8", "82599 Backplane"))
+	must(b.RenameSubsystem("101e", "1960", "101e", "0471", "MegaRAID 471"))
+	must(b.RenameClass("0c", "Serial bus"))
+	must(b.RenameSubclass("0c", "03", "USB"))
+	must(b.RenameProgIf("0c", "03", "30", "xHCI"))
+	db := b.DB()
+	checkIndexes(t, db)
+
+	if len(base.Products) != products || base.Vendors["103c"] == nil || base.Vendors["8086"].Name != "Intel Corporation" {
+		t.Fatalf("Expected the original DB to be unmodified")
+	}
+	if db.Vendors["103c"] != nil || db.Products["103c1030"] != nil {
+		t.Fatalf("Expected the removed vendor and its products to be gone")
+	}
+	megaRaid := db.Products["101e1960"]
+	if len(megaRaid.Subsystems) != 2 || megaRaid.Subsystems[0].Name != "MegaRAID 471" {
+		t.Fatalf("Expected the MegaRAID with 2 subsystems, but got %+v", megaRaid.Subsystems)
+	}
+	if s := megaRaid.Subsystems[1]; s.SubvendorID != "103c" || s.Subvendor != nil {
+		t.Fatalf("Expected the subsystem of the removed subvendor to be kept, unlinked, but got %+v", s)
+	}
+	if group := db.SubsystemsBySubvendor("1590"); group != nil {
+		t.Fatalf("Expected no subsystems of 1590 after removing their product, but got %+v", group)
+	}
+	if db.Vendors["8086"].Name != "Intel" || db.Products["808610f8"].Name != "82599 Backplane" {
+		t.Fatalf("Expected renamed vendor and product")
+	}
+	if db.Classes["02"] != nil || db.Subclasses["0200"] != nil || len(db.Classes["01"].Subclasses) != 2 {
+		t.Fatalf("Expected the removed class and subclass to be gone")
+	}
+	usb := db.Subclasses["0c03"]
+	if usb.Name != "USB" || len(usb.ProgrammingInterfaces) != 2 || usb.ProgrammingInterfaces[0].Name != "xHCI" {
+		t.Fatalf("Expected the renamed USB subclass with 2 programming interfaces, but got %+v", usb)
+	}
+
+	// The edited DB is the same as the one parsed from its pci-ids DB file
+	var out bytes.Buffer
+	if err := WriteText(&out, db, TextHeader{}); err != nil {
+		t.Fatalf("Expected no error writing DB, but got %v", err)
+	}
+	parsed := parseText(out.String(), nil)
+	if d := types.Diff(parsed, db); len(d.Changes) != 0 {
+		t.Fatalf("Expected no differences from the parsed DB but got %+v", d.Changes)
+	}
+
+	tests := []struct {
+		name string
+		edit func() error
+		want error
+	}{
+		{"removed vendor", func() error { return b.RemoveVendor("103c") }, types.ErrEntryNotFound},
+		{"removed product", func() error { return b.RenameProduct("103c", "1030", "x") }, types.ErrEntryNotFound},
+		{"removed subsystem", func() error { return b.RemoveSubsystem("101e", "1960", "1028", "0471") }, types.ErrEntryNotFound},
+		{"removed class", func() error { return b.RenameClass("02", "x") }, types.ErrEntryNotFound},
+		{"removed subclass", func() error { return b.RemoveSubclass("01", "08") }, types.ErrEntryNotFound},
+		{"removed programming interface", func() error { return b.RenameProgIf("0c", "03", "00", "x") }, types.ErrEntryNotFound},
+		{"short subvendor ID", func() error { return b.RenameSubsystem("101e", "1960", "101", "0471", "x") }, types.ErrInvalidID},
+		{"line break", func() error { return b.RenameVendor("8086", "Intel\nCorporation") }, types.ErrInvalidDB},
+	}
+	for _, test := range tests {
+		if err := test.edit(); !errors.Is(err, test.want) {
+			t.Fatalf("Expected %s to fail with %v but got %v", test.name, test.want, err)
+		}
+	}
+	if db.Vendors["8086"].Name != "Intel" {
+		t.Fatalf("Expected a rejected name not to be set")
+	}
+}
+
+// checkIndexes fails the test unless the flat maps, subvendor index and
+// subvendor links of the supplied DB are the ones built from its vendors and
+// classes
+func checkIndexes(t *testing.T, db *types.DB) {
+	t.Helper()
+	want := &types.DB{Vendors: db.Vendors, Classes: db.Classes}
+	indexDB(want)
+	if !reflect.DeepEqual(want.Products, db.Products) ||
+		!reflect.DeepEqual(want.Subsystems, db.Subsystems) ||
+		!reflect.DeepEqual(want.Subclasses, db.Subclasses) ||
+		!reflect.DeepEqual(want.ProgrammingInterfaces, db.ProgrammingInterfaces) {
+		t.Fatalf("Expected flat maps to match the DB's entries")
+	}
+	if len(want.SubvendorSubsystems) != len(db.SubvendorSubsystems) {
+		t.Fatalf("Expected %d subvendor index groups but got %d",
+			len(want.SubvendorSubsystems), len(db.SubvendorSubsystems))
+	}
+	for id, group := range want.SubvendorSubsystems {
+		if !reflect.DeepEqual(group, db.SubvendorSubsystems[id]) {
+			t.Fatalf("Expected subvendor index group %s to match the DB's subsystems", id)
+		}
+	}
+	for key, s := range db.Subsystems {
+		if s.Subvendor != db.Vendors[s.SubvendorID] {
+			t.Fatalf("Expected subsystem %s to be linked to its subvendor", key)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// Cache manages the pci-ids DB files that pcidb stores in its cache
+// directory.
+//
+// Besides the current cache file, the cache keeps a versioned store of every
+// pci-ids DB file that has been fetched or pinned, in a directory next to the
+// cache file named after it with a ".versions" suffix. Files in the versioned
+// store are named after the "Version:" header of the DB file and the first 12
+// hex characters of the SHA-256 digest of its uncompressed contents, e.g.
+// $HOME/.cache/pci.ids.versions/2025.08.20-1f3a9c0d7e21.ids
+type Cache struct {
+	path      string
+	compress  bool
+	retention int
+	fetchURL  string
+}
+
+// NewCache returns a Cache for the cache path described by the supplied
+// options.
+func NewCache(opts *types.WithOption) *Cache {
+	path := types.DefaultCachePath
+	if opts.CachePath != nil && *opts.CachePath != "" {
+		path = *opts.CachePath
+	}
+	retention := types.DefaultCacheRetention
+	if opts.CacheRetention != nil {
+		retention = *opts.CacheRetention
+	}
+	return &Cache{
+		path:      path,
+		compress:  cacheCompress(opts),
+		retention: retention,
+		fetchURL:  fetchURL(opts),
+	}
+}
+
+// Path returns the path of the (uncompressed) cache file.
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// List returns information about the current cache file and each pci-ids DB
+// file in the versioned cache store. Current cache files are listed first,
+// followed by versioned files sorted newest first.
+func (c *Cache) List() ([]*types.CacheEntry, error) {
+	if c.path == "" {
+		return nil, types.ErrNoPaths
+	}
+	paths := []string{c.path}
+	if gzPath := compressedCachePath(c.path); gzPath != c.path {
+		paths = append(paths, gzPath)
+	}
+	entries := []*types.CacheEntry{}
+	for _, fp := range paths {
+		entry, err := c.entry(fp)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ModTime.After(entries[j].ModTime)
+	})
+	versioned, err := c.versions()
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, versioned...), nil
+}
+
+// versions returns the entries in the versioned cache store, newest first
+func (c *Cache) versions() ([]*types.CacheEntry, error) {
+	dir := versionsDir(c.path)
+	files, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	entries := make([]*types.CacheEntry, 0, len(files))
+	for _, file := range files {
+		// Skip hidden files, such as the temporary files that versioned
+		// files are written to before being renamed into place
+		name := file.Name()
+		if strings.HasPrefix(name, ".") ||
+			!(strings.HasSuffix(name, ".ids") || strings.HasSuffix(name, ".ids.gz")) {
+			continue
+		}
+		entry, err := c.entry(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry.Versioned = true
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Date != entries[j].Date {
+			return entries[i].Date > entries[j].Date
+		}
+		return entries[i].ModTime.After(entries[j].ModTime)
+	})
+	return entries, nil
+}
+
+func (c *Cache) entry(fp string) (*types.CacheEntry, error) {
+	fi, err := os.Stat(fp)
+	if err != nil {
+		return nil, err
+	}
+	entry := &types.CacheEntry{
+		Path:       fp,
+		Size:       fi.Size(),
+		ModTime:    fi.ModTime(),
+		Compressed: strings.HasSuffix(fp, ".gz"),
+	}
+	f, err := openDBFile(fp)
+	if err != nil {
+		// an unreadable file is still listed so that it can be verified and
+		// pruned
+		return entry, nil
+	}
+	defer f.Close()
+	h := sha256.New()
+	tr := io.TeeReader(f, h)
+	entry.Version, entry.Date = readHeader(tr)
+	if _, err := io.Copy(io.Discard, tr); err != nil {
+		return entry, nil
+	}
+	entry.Checksum = hex.EncodeToString(h.Sum(nil))
+	return entry, nil
+}
+
+// Verify checks that the cached file described by the supplied entry can be
+// opened, read in full and parsed as a pci-ids DB file, returning an error
+// wrapping types.ErrInvalidDB if reading fails, for example because a
+// gzip-compressed file is truncated or corrupt, or if it contains no vendor or
+// class information.
+func (c *Cache) Verify(entry *types.CacheEntry) error {
+	f, err := openDBFile(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	text, err := readText(f)
+	if err != nil {
+		return fmt.Errorf("%w: reading %s: %v", types.ErrInvalidDB, entry.Path, err)
+	}
+	db := parseText(text, nil)
+	if len(db.Vendors) == 0 || len(db.Classes) == 0 {
+		return types.ErrInvalidDB
+	}
+	return nil
+}
+
+// Prune removes stale files from the cache and returns the entries that were
+// removed. A cached file is stale if it fails verification or, when maxAge is
+// greater than zero, if it was last modified longer than maxAge ago. Versioned
+// files beyond the configured retention count are also removed, oldest first.
+func (c *Cache) Prune(maxAge time.Duration) ([]*types.CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	removed := []*types.CacheEntry{}
+	kept := 0
+	for _, entry := range entries {
+		stale := maxAge > 0 && time.Since(entry.ModTime) > maxAge
+		if !stale && c.Verify(entry) == nil {
+			if !entry.Versioned {
+				continue
+			}
+			kept++
+			if c.retention <= 0 || kept <= c.retention {
+				continue
+			}
+		}
+		if err := os.Remove(entry.Path); err != nil {
+			return removed, err
+		}
+		if !entry.Versioned {
+			os.Remove(compiledPath(c.path))
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
+
+// Fetch downloads the latest pci-ids DB file from the network, or from the
+// URL set with types.WithFetchURL, into the cache, replacing any
+// previously-cached copy, and returns the new entry. A copy of the fetched
+// file is kept in the versioned cache store.
+func (c *Cache) Fetch() (*types.CacheEntry, error) {
+	if c.path == "" {
+		return nil, types.ErrNoPaths
+	}
+	data, err := fetchDBFile(c.fetchURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.save(data)
+}
+
+// save writes the supplied pci-ids DB file contents to the cache, replacing
+// any previously-cached copy, keeps a copy in the versioned cache store and
+// returns the new entry.
+func (c *Cache) save(data []byte) (*types.CacheEntry, error) {
+	if c.path == "" {
+		return nil, types.ErrNoPaths
+	}
+	fp, err := writeCacheFile(c.path, data, c.compress)
+	if err != nil {
+		return nil, err
+	}
+	// Remove the copy stored in the other format so that discovery does not
+	// keep returning an older file.
+	stale := compressedCachePath(c.path)
+	if c.compress {
+		stale = c.path
+	}
+	if stale != fp {
+		if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	entry, err := c.entry(fp)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.store(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// CompiledPath returns the path that the compiled form of the cached pci-ids
+// DB file is stored at. See OpenCompiled.
+func (c *Cache) CompiledPath() string {
+	return compiledPath(c.path)
+}
+
+// Compile stores the compiled form of the current cache file at CompiledPath,
+// so that subsequent loads of the cache file use it instead of parsing the
+// file for as long as the file is unchanged, and returns the path of the
+// cache file it was compiled from.
+func (c *Cache) Compile() (string, error) {
+	if c.path == "" {
+		return "", types.ErrNoPaths
+	}
+	// Prefer the same cache file that discovery does
+	for _, fp := range []string{c.path, compressedCachePath(c.path)} {
+		fi, err := os.Stat(fp)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		f, err := openDBFile(fp)
+		if err != nil {
+			return "", err
+		}
+		db := fromReader(f, nil)
+		if err := writeCompiledCache(c.path, db, fp, fi); err != nil {
+			return "", err
+		}
+		return fp, nil
+	}
+	return "", types.ErrNoDB
+}
+
+// Pin copies the pci-ids DB file at the supplied path (for example, the
+// host's /usr/share/hwdata/pci.ids) into the versioned cache store so that
+// the same version can later be loaded with types.WithVersion, and returns
+// the versioned entry.
+func (c *Cache) Pin(path string) (*types.CacheEntry, error) {
+	if c.path == "" {
+		return nil, types.ErrNoPaths
+	}
+	entry, err := c.entry(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Checksum == "" {
+		return nil, types.ErrInvalidDB
+	}
+	return c.store(entry)
+}
+
+// Find returns the versioned cache entry for the supplied version and/or
+// checksum. Either may be empty, in which case it matches any entry. The
+// newest matching entry is returned.
+func (c *Cache) Find(version string, checksum string) (*types.CacheEntry, error) {
+	entries, err := c.versions()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if version != "" && entry.Version != version {
+			continue
+		}
+		if checksum != "" && !strings.EqualFold(entry.Checksum, checksum) {
+			continue
+		}
+		return entry, nil
+	}
+	return nil, types.ErrVersionNotFound
+}
+
+// store links or copies the file described by the supplied entry into the
+// versioned cache store, applies the retention policy and returns the
+// versioned entry
+func (c *Cache) store(entry *types.CacheEntry) (*types.CacheEntry, error) {
+	dir := versionsDir(c.path)
+	if err := os.MkdirAll(dir, types.DefaultCacheDirMode); err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(dir, versionFileName(entry))
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		if err := os.Link(entry.Path, dest); err != nil {
+			if err := copyFile(entry.Path, dest); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.retention > 0 {
+		versioned, err := c.versions()
+		if err != nil {
+			return nil, err
+		}
+		for x := c.retention; x < len(versioned); x++ {
+			if versioned[x].Path == dest {
+				continue
+			}
+			if err := os.Remove(versioned[x].Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	stored, err := c.entry(dest)
+	if err != nil {
+		return nil, err
+	}
+	stored.Versioned = true
+	return stored, nil
+}
+
+// versionsDir returns the directory that the versioned cache store for the
+// supplied cache path lives in
+func versionsDir(cachePath string) string {
+	return cachePath + ".versions"
+}
+
+// versionFileName returns the name of the file that the supplied entry is
+// stored as in the versioned cache store
+func versionFileName(entry *types.CacheEntry) string {
+	version := entry.Version
+	if version == "" {
+		version, _, _ = strings.Cut(entry.Date, " ")
+	}
+	if version == "" {
+		version = "unknown"
+	}
+	version = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.' || r == '-' || r == '_':
+			return r
+		}
+		return '_'
+	}, version)
+	name := fmt.Sprintf("%s-%s.ids", version, entry.Checksum[:12])
+	if entry.Compressed {
+		name += ".gz"
+	}
+	return name
+}
+
+// copyFile atomically copies the file at src to dest with the default cache
+// file mode
+func copyFile(src string, dest string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(
+		filepath.Dir(dest), "."+filepath.Base(dest)+".*",
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(out.Name())
+		}
+	}()
+	defer out.Close()
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	if err = out.Chmod(types.DefaultCacheFileMode); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dest)
+}
+
+// verifyFileChecksum returns an error wrapping types.ErrChecksumMismatch if
+// the uncompressed contents of the pci-ids DB file at the supplied path do not
+// match the supplied hex-encoded SHA-256 digest
+func verifyFileChecksum(path string, checksum string) error {
+	f, err := openDBFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return verifyChecksum(path, f, checksum)
+}
+
+// verifyChecksum returns an error wrapping types.ErrChecksumMismatch if the
+// contents of the supplied reader do not match the supplied hex-encoded
+// SHA-256 digest. The name is used to identify the source in the error.
+func verifyChecksum(name string, r io.Reader, checksum string) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, checksum) {
+		return fmt.Errorf(
+			"%w: %s has checksum %s, expected %s",
+			types.ErrChecksumMismatch, name, actual, checksum,
+		)
+	}
+	return nil
+}
+
+// readHeader returns the Version and Date values from the header comment
+// block at the top of a pci-ids DB file. The header looks like this:
+//
+// #	Version: 2025.08.20
+// #	Date:    2025-08-20 03:15:02
+func readHeader(r io.Reader) (version string, date string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		field := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if val, ok := strings.CutPrefix(field, "Version:"); ok {
+			version = strings.TrimSpace(val)
+		} else if val, ok := strings.CutPrefix(field, "Date:"); ok {
+			date = strings.TrimSpace(val)
+		}
+	}
+	return version, date
+}
@@ -0,0 +1,212 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "pci.ids")
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	if err := os.WriteFile(cachePath, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing cache file, but got %v", err)
+	}
+	// A truncated, corrupt compressed copy that should fail verification
+	if err := os.WriteFile(cachePath+".gz", []byte("junk"), 0o644); err != nil {
+		t.Fatalf("Expected no error writing cache file, but got %v", err)
+	}
+
+	cache := NewCache(MergeOptions(types.WithCachePath(cachePath)))
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatalf("Expected no error listing cache, but got %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 cache entries, but got %d", len(entries))
+	}
+	var plain, compressed *types.CacheEntry
+	for _, e := range entries {
+		if e.Compressed {
+			compressed = e
+		} else {
+			plain = e
+		}
+	}
+	if plain == nil || compressed == nil {
+		t.Fatalf("Expected a plain and a compressed entry, got %+v", entries)
+	}
+	if plain.Version != "2025.08.20" {
+		t.Fatalf("Expected version 2025.08.20 but got %q", plain.Version)
+	}
+	if plain.Date != "2025-08-20 03:15:02" {
+		t.Fatalf("Expected date 2025-08-20 03:15:02 but got %q", plain.Date)
+	}
+	if err := cache.Verify(plain); err != nil {
+		t.Fatalf("Expected plain entry to verify, but got %v", err)
+	}
+	if err := cache.Verify(compressed); err == nil {
+		t.Fatalf("Expected corrupt compressed entry to fail verification")
+	}
+
+	removed, err := cache.Prune(0)
+	if err != nil {
+		t.Fatalf("Expected no error pruning cache, but got %v", err)
+	}
+	if len(removed) != 1 || removed[0].Path != cachePath+".gz" {
+		t.Fatalf("Expected only the corrupt entry to be pruned, got %+v", removed)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(cachePath, old, old); err != nil {
+		t.Fatalf("Expected no error changing mtime, but got %v", err)
+	}
+	removed, err = cache.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Expected no error pruning cache, but got %v", err)
+	}
+	if len(removed) != 1 {
+		t.Fatalf("Expected the stale entry to be pruned, got %+v", removed)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed", cachePath)
+	}
+}
+
+func TestCacheVerifyTruncated(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(contents)
+	zw.Close()
+	data := buf.Bytes()
+
+	// Cutting off the gzip trailer leaves every vendor and class readable,
+	// and cutting the stream in half leaves only some of them
+	for _, size := range []int{len(data) - 4, len(data) / 2} {
+		cachePath := filepath.Join(t.TempDir(), "pci.ids")
+		if err := os.WriteFile(cachePath+".gz", data[:size], 0o644); err != nil {
+			t.Fatalf("Expected no error writing cache file, but got %v", err)
+		}
+		cache := NewCache(MergeOptions(types.WithCachePath(cachePath)))
+		entries, err := cache.List()
+		if err != nil {
+			t.Fatalf("Expected no error listing cache, but got %v", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("Expected 1 cache entry, but got %d", len(entries))
+		}
+		if err := cache.Verify(entries[0]); !errors.Is(err, types.ErrInvalidDB) {
+			t.Fatalf("Expected ErrInvalidDB verifying %d of %d bytes, but got %v", size, len(data), err)
+		}
+		removed, err := cache.Prune(0)
+		if err != nil {
+			t.Fatalf("Expected no error pruning cache, but got %v", err)
+		}
+		if len(removed) != 1 {
+			t.Fatalf("Expected the truncated entry to be pruned, got %+v", removed)
+		}
+	}
+}
+
+func TestCacheVersions(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "pci.ids")
+	fixture := basicFixture
+	contents, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	older := filepath.Join(dir, "older.ids")
+	olderContents := []byte(
+		"#\tVersion: 2024.01.01\n#\tDate:    2024-01-01 03:15:02\n" +
+			string(contents[strings.Index(string(contents), "\n0e11")+1:]),
+	)
+	if err := os.WriteFile(older, olderContents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+
+	cache := NewCache(MergeOptions(
+		types.WithCachePath(cachePath), types.WithCacheRetention(1),
+	))
+	pinnedOld, err := cache.Pin(older)
+	if err != nil {
+		t.Fatalf("Expected no error pinning older DB, but got %v", err)
+	}
+	if !pinnedOld.Versioned || pinnedOld.Version != "2024.01.01" {
+		t.Fatalf("Expected versioned 2024.01.01 entry, but got %+v", pinnedOld)
+	}
+
+	pinned, err := cache.Pin(fixture)
+	if err != nil {
+		t.Fatalf("Expected no error pinning DB, but got %v", err)
+	}
+	if pinned.Version != "2025.08.20" {
+		t.Fatalf("Expected version 2025.08.20 but got %q", pinned.Version)
+	}
+	// Retention of 1 means the older version was evicted
+	if _, err := os.Stat(pinnedOld.Path); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be evicted by retention", pinnedOld.Path)
+	}
+	// A temporary file left behind by an interrupted write isn't a version
+	tmp := filepath.Join(versionsDir(cachePath), "."+filepath.Base(pinned.Path)+".123")
+	if err := os.WriteFile(tmp, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatalf("Expected no error listing cache, but got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != pinned.Path {
+		t.Fatalf("Expected only the pinned entry, but got %+v", entries)
+	}
+
+	opts := MergeOptions(
+		types.WithCachePath(cachePath),
+		types.WithVersion("2025.08.20"),
+		types.WithExpectedChecksum(pinned.Checksum),
+	)
+	f, err := Discover(opts)
+	if err != nil {
+		t.Fatalf("Expected no error discovering pinned DB, but got %v", err)
+	}
+	db := FromReader(f)
+	if _, exists := db.Vendors["8086"]; !exists {
+		t.Fatalf("Expected to find Intel vendor in pinned DB")
+	}
+
+	_, err = Discover(MergeOptions(
+		types.WithCachePath(cachePath), types.WithVersion("2024.01.01"),
+	))
+	if !errors.Is(err, types.ErrVersionNotFound) {
+		t.Fatalf("Expected ErrVersionNotFound but got %v", err)
+	}
+
+	_, err = Discover(MergeOptions(
+		types.WithPath(fixture),
+		types.WithExpectedChecksum(strings.Repeat("0", 64)),
+	))
+	if !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch but got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestComments(t *testing.T) {
+	contents := strings.Join([]string{
+		"# List of PCI ID's",
+		"",
+		"# Also used by the Compaq Smart Array 6i",
+		"#",
+		"#\tsee https://example.com/compaq",
+		"0e11  Compaq Computer Corporation",
+		"\t0001  PCI to EISA Bridge",
+		"# Withdrawn",
+		"\t\t0e11 4091  Smart Array 6i",
+		"# Not attached: followed by a blank line",
+		"",
+		"1000  Broadcom / LSI",
+		"# Dropped along with the vendor it is attached to",
+		"dead  Skipped Vendor",
+		"# Also dropped",
+		"\t0001  Skipped Product",
+		"# Attached to the class after the skipped vendor",
+		"C 0c  Serial bus controller",
+		"\t03  USB controller",
+		"#Unusual comment",
+		"\t\t30  XHCI",
+	}, "\n")
+	opts := MergeOptions(
+		types.WithComments(),
+		types.WithVendors("0e11", "1000"),
+	)
+	db := parseText(contents, newFilter(opts))
+
+	compaq := db.Vendors["0e11"]
+	want := []string{"Also used by the Compaq Smart Array 6i", "", "\tsee https://example.com/compaq"}
+	if !reflect.DeepEqual(compaq.Comments, want) {
+		t.Fatalf("Expected vendor comments %q but got %q", want, compaq.Comments)
+	}
+	if compaq.Products[0].Comments != nil {
+		t.Fatalf("Expected no product comments but got %q", compaq.Products[0].Comments)
+	}
+	if got := compaq.Products[0].Subsystems[0].Comments; !reflect.DeepEqual(got, []string{"Withdrawn"}) {
+		t.Fatalf("Expected subsystem comment but got %q", got)
+	}
+	if got := db.Vendors["1000"].Comments; got != nil {
+		t.Fatalf("Expected comment followed by a blank line to be dropped, but got %q", got)
+	}
+	serial := db.Classes["0c"]
+	if !reflect.DeepEqual(serial.Comments, []string{"Attached to the class after the skipped vendor"}) {
+		t.Fatalf("Expected only the class comment after the skipped vendor, but got %q", serial.Comments)
+	}
+	xhci := serial.Subclasses[0].ProgrammingInterfaces[0]
+	if !reflect.DeepEqual(xhci.Comments, []string{"Unusual comment"}) {
+		t.Fatalf("Expected programming interface comment but got %q", xhci.Comments)
+	}
+
+	if db := parseText(contents, nil); db.Vendors["0e11"].Comments != nil {
+		t.Fatalf("Expected comments to only be kept when requested")
+	}
+
+	// The written file attaches the comments to the same entries
+	var out bytes.Buffer
+	if err := WriteText(&out, db, TextHeader{}); err != nil {
+		t.Fatalf("Expected no error writing DB, but got %v", err)
+	}
+	if !strings.Contains(out.String(), "# Also used by the Compaq Smart Array 6i\n#\n# \tsee https://example.com/compaq\n0e11  ") {
+		t.Fatalf("Expected vendor comments above vendor line, but got\n%s", out.String())
+	}
+	reparsed := parseText(out.String(), &filter{comments: true})
+	wantJSON, _ := json.Marshal(db)
+	gotJSON, _ := json.Marshal(reparsed)
+	if string(wantJSON) != string(gotJSON) {
+		t.Fatalf("Expected written DB to parse back to the same DB\nwant: %s\ngot:  %s", wantJSON, gotJSON)
+	}
+}
+
+func TestCommentsFixture(t *testing.T) {
+	path := basicFixture
+	opts := MergeOptions(
+		types.WithPath(path),
+		types.WithComments(),
+		types.WithDisableMemoization(),
+	)
+	db, err := Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	want := []string{"Only for some ConnectX-6 Dx firmware versions"}
+	if got := db.Products["15b3101d"].Comments; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected product comments %q but got %q", want, got)
+	}
+	if got := db.Vendors["0e11"].Comments; got != nil {
+		t.Fatalf("Expected header comments not to be attached, but got %q", got)
+	}
+
+	lazy, err := LoadLazy(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	if got := lazy.Product("15b3", "101d").Comments; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected lazy product comments %q but got %q", want, got)
+	}
+	lazyJSON, _ := json.Marshal(lazy.DB())
+	eagerJSON, _ := json.Marshal(db)
+	if string(lazyJSON) != string(eagerJSON) {
+		t.Fatalf("Expected lazy DB with comments to match parsed DB\nwant: %s\ngot:  %s", eagerJSON, lazyJSON)
+	}
+}
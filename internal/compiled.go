@@ -0,0 +1,691 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// The compiled DB format is a little-endian binary encoding of a DB made of
+// fixed-size records in sorted tables, followed by a pool of the names they
+// reference:
+//
+//	header     see the hdr* offsets below
+//	vendors    sorted by ID, each referencing a range of products
+//	products   sorted by vendor ID then product ID, each referencing a range
+//	           of subsystems
+//	subsystems grouped by product
+//	classes    sorted by ID, each referencing a range of subclasses
+//	subclasses grouped by class, each referencing a range of programming
+//	           interfaces
+//	progifaces grouped by subclass
+//	strings    the string pool
+//
+// Only the tables that are searched are sorted, so the products of each vendor
+// in a DB materialised from the compiled form are ordered by ID. Subsystems,
+// subclasses and programming interfaces keep the order of the DB they were
+// compiled from.
+//
+// Names are stored as an offset and length into the string pool. The header
+// also records the version of the source pci-ids DB file and the path, size
+// and modification time of the file it was compiled from so that a compiled
+// cache can be checked for staleness.
+const (
+	compiledMagic   = "PCIDBC"
+	compiledVersion = 1
+
+	hdrMagic         = 0
+	hdrFormatVersion = 6
+	hdrCounts        = 8 // six uint32 table lengths
+	hdrPoolLen       = 32
+	hdrSourceSize    = 36
+	hdrSourceModTime = 44
+	hdrVersion       = 52 // string reference
+	hdrSourcePath    = 60 // string reference
+	hdrLen           = 68
+
+	// vendor, product, class and subclass records: a 4-byte ID field, a
+	// string reference and a child range
+	parentRecLen = 20
+	// subsystem and programming interface records: a 4-byte ID field and a
+	// string reference
+	leafRecLen = 12
+)
+
+const (
+	tableVendors = iota
+	tableProducts
+	tableSubsystems
+	tableClasses
+	tableSubclasses
+	tableProgIfaces
+	numTables
+)
+
+var tableRecLens = [numTables]int{
+	parentRecLen, parentRecLen, leafRecLen,
+	parentRecLen, parentRecLen, leafRecLen,
+}
+
+// CompiledSource describes the pci-ids DB file a compiled DB was produced
+// from
+type CompiledSource struct {
+	// Version is the value of the "Version:" header comment of the source
+	// pci-ids DB file
+	Version string
+	// Path is the path of the source pci-ids DB file, if known
+	Path string
+	// Size is the size of the source pci-ids DB file
+	Size int64
+	// ModTime is the modification time of the source pci-ids DB file
+	ModTime time.Time
+}
+
+// Compiled is a read-only DB in the compiled binary format that answers
+// lookups directly from the encoded tables without materialising the vendor,
+// product and class maps.
+type Compiled struct {
+	data    []byte
+	release func() error
+	tables  [numTables]int // table offsets
+	counts  [numTables]int
+	pool    int // string pool offset
+	poolLen int
+	// names, if set, is a copy of the whole string pool that str returns
+	// substrings of. It is only set on the copy that DB materialises from.
+	names string
+}
+
+// compiledWriter accumulates the tables and string pool of a compiled DB
+type compiledWriter struct {
+	tables  [numTables][]byte
+	counts  [numTables]int
+	pool    strings.Builder
+	poolIdx map[string]uint32
+}
+
+func (cw *compiledWriter) str(s string) [8]byte {
+	off, exists := cw.poolIdx[s]
+	if !exists {
+		off = uint32(cw.pool.Len())
+		cw.pool.WriteString(s)
+		cw.poolIdx[s] = off
+	}
+	var ref [8]byte
+	binary.LittleEndian.PutUint32(ref[0:], off)
+	binary.LittleEndian.PutUint32(ref[4:], uint32(len(s)))
+	return ref
+}
+
+func (cw *compiledWriter) add(
+	table int, id uint32, name string, first int, count int,
+) {
+	rec := make([]byte, tableRecLens[table])
+	binary.LittleEndian.PutUint32(rec[0:], id)
+	ref := cw.str(name)
+	copy(rec[4:], ref[:])
+	if tableRecLens[table] == parentRecLen {
+		binary.LittleEndian.PutUint32(rec[12:], uint32(first))
+		binary.LittleEndian.PutUint32(rec[16:], uint32(count))
+	}
+	cw.tables[table] = append(cw.tables[table], rec...)
+	cw.counts[table]++
+}
+
+// WriteCompiled encodes the supplied DB in the compiled binary format. The
+// source describes the pci-ids DB file the DB was parsed from.
+func WriteCompiled(w io.Writer, db *types.DB, source CompiledSource) error {
+	cw := &compiledWriter{poolIdx: map[string]uint32{}}
+
+	vendors := make([]*types.Vendor, 0, len(db.Vendors))
+	for _, v := range db.Vendors {
+		vendors = append(vendors, v)
+	}
+	sort.Slice(vendors, func(i, j int) bool { return vendors[i].ID < vendors[j].ID })
+	for _, v := range vendors {
+		vid, err := parseHexID(v.ID, 16)
+		if err != nil {
+			return err
+		}
+		products := append([]*types.Product(nil), v.Products...)
+		sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+		cw.add(tableVendors, vid, v.Name, cw.counts[tableProducts], len(products))
+		for _, p := range products {
+			pid, err := parseHexID(p.ID, 16)
+			if err != nil {
+				return err
+			}
+			cw.add(
+				tableProducts, vid<<16|pid, p.Name,
+				cw.counts[tableSubsystems], len(p.Subsystems),
+			)
+			for _, s := range p.Subsystems {
+				svid, err := parseHexID(s.SubvendorID, 16)
+				if err != nil {
+					return err
+				}
+				sdid, err := parseHexID(s.SubdeviceID, 16)
+				if err != nil {
+					return err
+				}
+				cw.add(tableSubsystems, svid<<16|sdid, s.Name, 0, 0)
+			}
+		}
+	}
+
+	classes := make([]*types.Class, 0, len(db.Classes))
+	for _, c := range db.Classes {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].ID < classes[j].ID })
+	for _, c := range classes {
+		cid, err := parseHexID(c.ID, 8)
+		if err != nil {
+			return err
+		}
+		cw.add(tableClasses, cid, c.Name, cw.counts[tableSubclasses], len(c.Subclasses))
+		for _, sc := range c.Subclasses {
+			scid, err := parseHexID(sc.ID, 8)
+			if err != nil {
+				return err
+			}
+			cw.add(
+				tableSubclasses, scid, sc.Name,
+				cw.counts[tableProgIfaces], len(sc.ProgrammingInterfaces),
+			)
+			for _, pi := range sc.ProgrammingInterfaces {
+				piid, err := parseHexID(pi.ID, 8)
+				if err != nil {
+					return err
+				}
+				cw.add(tableProgIfaces, piid, pi.Name, 0, 0)
+			}
+		}
+	}
+
+	hdr := make([]byte, hdrLen)
+	copy(hdr[hdrMagic:], compiledMagic)
+	binary.LittleEndian.PutUint16(hdr[hdrFormatVersion:], compiledVersion)
+	for x := 0; x < numTables; x++ {
+		binary.LittleEndian.PutUint32(hdr[hdrCounts+4*x:], uint32(cw.counts[x]))
+	}
+	versionRef := cw.str(source.Version)
+	copy(hdr[hdrVersion:], versionRef[:])
+	pathRef := cw.str(source.Path)
+	copy(hdr[hdrSourcePath:], pathRef[:])
+	binary.LittleEndian.PutUint32(hdr[hdrPoolLen:], uint32(cw.pool.Len()))
+	binary.LittleEndian.PutUint64(hdr[hdrSourceSize:], uint64(source.Size))
+	binary.LittleEndian.PutUint64(hdr[hdrSourceModTime:], uint64(source.ModTime.UnixNano()))
+
+	bw := bufio.NewWriter(w)
+	bw.Write(hdr)
+	for x := 0; x < numTables; x++ {
+		bw.Write(cw.tables[x])
+	}
+	bw.WriteString(cw.pool.String())
+	return bw.Flush()
+}
+
+// OpenCompiled opens the compiled DB file at the supplied path. Where
+// supported, the file is memory-mapped rather than read. The returned
+// Compiled must be closed when no longer needed.
+func OpenCompiled(path string) (*Compiled, error) {
+	data, release, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCompiled(data)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("%w: %s", err, path)
+	}
+	c.release = release
+	return c, nil
+}
+
+// newCompiled validates the supplied compiled DB data and returns a Compiled
+// that reads from it
+func newCompiled(data []byte) (*Compiled, error) {
+	if len(data) < hdrLen || string(data[hdrMagic:hdrMagic+len(compiledMagic)]) != compiledMagic {
+		return nil, types.ErrInvalidDB
+	}
+	if binary.LittleEndian.Uint16(data[hdrFormatVersion:]) != compiledVersion {
+		return nil, types.ErrInvalidDB
+	}
+	c := &Compiled{data: data}
+	off := hdrLen
+	for x := 0; x < numTables; x++ {
+		c.counts[x] = int(binary.LittleEndian.Uint32(data[hdrCounts+4*x:]))
+		c.tables[x] = off
+		off += c.counts[x] * tableRecLens[x]
+	}
+	c.pool = off
+	c.poolLen = int(binary.LittleEndian.Uint32(data[hdrPoolLen:]))
+	if off < hdrLen || c.pool+c.poolLen != len(data) {
+		return nil, types.ErrInvalidDB
+	}
+	// Check every string reference and child range up front so that lookups
+	// never index out of bounds on a corrupt file
+	if !c.validRef(hdrVersion) || !c.validRef(hdrSourcePath) {
+		return nil, types.ErrInvalidDB
+	}
+	children := [numTables]int{
+		tableVendors:    tableProducts,
+		tableProducts:   tableSubsystems,
+		tableClasses:    tableSubclasses,
+		tableSubclasses: tableProgIfaces,
+	}
+	for x := 0; x < numTables; x++ {
+		for i := 0; i < c.counts[x]; i++ {
+			rec := c.rec(x, i)
+			if !c.validRef(rec + 4) {
+				return nil, types.ErrInvalidDB
+			}
+			if tableRecLens[x] == parentRecLen {
+				first, count := c.children(rec)
+				if first+count > c.counts[children[x]] {
+					return nil, types.ErrInvalidDB
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// Close releases the compiled DB's underlying memory. Any vendors, products
+// and classes previously returned remain valid.
+func (c *Compiled) Close() error {
+	if c.release == nil {
+		return nil
+	}
+	release := c.release
+	c.release = nil
+	c.data = nil
+	return release()
+}
+
+// Source returns information about the pci-ids DB file the compiled DB was
+// produced from.
+func (c *Compiled) Source() CompiledSource {
+	return CompiledSource{
+		Version: c.str(hdrVersion),
+		Path:    c.str(hdrSourcePath),
+		Size:    int64(binary.LittleEndian.Uint64(c.data[hdrSourceSize:])),
+		ModTime: time.Unix(0, int64(binary.LittleEndian.Uint64(c.data[hdrSourceModTime:]))),
+	}
+}
+
+// Vendor returns the vendor with the supplied hex-encoded ID, including its
+// products and their subsystems, or nil if there is no such vendor.
+func (c *Compiled) Vendor(id string) *types.Vendor {
+	vid, err := parseHexID(id, 16)
+	if err != nil {
+		return nil
+	}
+	return c.VendorByID(types.VendorID(vid))
+}
+
+// VendorByID returns the vendor with the supplied vendor ID, including its
+// products and their subsystems, or nil if there is no such vendor.
+func (c *Compiled) VendorByID(id types.VendorID) *types.Vendor {
+	x, found := c.search(tableVendors, 0, c.counts[tableVendors], uint32(id))
+	if !found {
+		return nil
+	}
+	v := c.vendor(x)
+	c.linkSubvendors(v, v.Products...)
+	return v
+}
+
+// Product returns the product with the supplied hex-encoded vendor and
+// product IDs, including its subsystems, or nil if there is no such product.
+func (c *Compiled) Product(vendorID string, productID string) *types.Product {
+	vid, err := parseHexID(vendorID, 16)
+	if err != nil {
+		return nil
+	}
+	pid, err := parseHexID(productID, 16)
+	if err != nil {
+		return nil
+	}
+	return c.ProductByID(types.VendorID(vid), types.DeviceID(pid))
+}
+
+// ProductByID returns the product with the supplied vendor and device IDs,
+// including its subsystems, or nil if there is no such product.
+func (c *Compiled) ProductByID(
+	vendorID types.VendorID,
+	deviceID types.DeviceID,
+) *types.Product {
+	id := uint32(vendorID)<<16 | uint32(deviceID)
+	x, found := c.search(tableProducts, 0, c.counts[tableProducts], id)
+	if !found {
+		return nil
+	}
+	p := c.product(x)
+	p.Vendor = c.vendorHeader(vendorID)
+	c.linkSubvendors(p.Vendor, p)
+	return p
+}
+
+// SubsystemsBySubvendor returns the subsystems of every product that have the
+// supplied hex-encoded subvendor ID, ordered by vendor and product ID. The
+// Product field of each subsystem is the product it is a subsystem of.
+func (c *Compiled) SubsystemsBySubvendor(id string) []*types.Subsystem {
+	svid, err := parseHexID(id, 16)
+	if err != nil {
+		return nil
+	}
+	return c.SubsystemsBySubvendorID(types.VendorID(svid))
+}
+
+// SubsystemsBySubvendorID returns the subsystems of every product that have
+// the supplied subvendor ID. See SubsystemsBySubvendor.
+func (c *Compiled) SubsystemsBySubvendorID(id types.VendorID) []*types.Subsystem {
+	var found []*types.Subsystem
+	for x := 0; x < c.counts[tableProducts]; x++ {
+		first, count := c.children(c.rec(tableProducts, x))
+		var p *types.Product
+		for i := 0; i < count; i++ {
+			if types.VendorID(c.id(c.rec(tableSubsystems, first+i))>>16) != id {
+				continue
+			}
+			if p == nil {
+				p = c.product(x)
+				p.Vendor = c.vendorHeader(p.NumericVendorID)
+				c.linkSubvendors(p.Vendor, p)
+			}
+			found = append(found, p.Subsystems[i])
+		}
+	}
+	return found
+}
+
+// Class returns the class with the supplied hex-encoded ID, including its
+// subclasses and programming interfaces, or nil if there is no such class.
+func (c *Compiled) Class(id string) *types.Class {
+	cid, err := parseHexID(id, 8)
+	if err != nil {
+		return nil
+	}
+	return c.ClassByID(types.ClassID(cid))
+}
+
+// ClassByID returns the class with the supplied class ID, including its
+// subclasses and programming interfaces, or nil if there is no such class.
+func (c *Compiled) ClassByID(id types.ClassID) *types.Class {
+	x, found := c.search(tableClasses, 0, c.counts[tableClasses], uint32(id))
+	if !found {
+		return nil
+	}
+	return c.class(x)
+}
+
+// DB materialises the entire compiled DB. The products of each vendor are
+// ordered by ID rather than in the order of the pci-ids DB file the DB was
+// compiled from.
+func (c *Compiled) DB() *types.DB {
+	// Copy the whole string pool once instead of each name separately. The
+	// pool is set on a copy of c so that concurrent lookups are unaffected.
+	all := *c
+	all.names = string(c.data[c.pool:])
+	c = &all
+	db := &types.DB{
+		Classes: make(map[string]*types.Class, c.counts[tableClasses]),
+		Vendors: make(map[string]*types.Vendor, c.counts[tableVendors]),
+	}
+	for x := 0; x < c.counts[tableVendors]; x++ {
+		v := c.vendor(x)
+		db.Vendors[v.ID] = v
+	}
+	for x := 0; x < c.counts[tableClasses]; x++ {
+		cls := c.class(x)
+		db.Classes[cls.ID] = cls
+	}
+	indexDB(db)
+	for _, p := range db.Products {
+		for _, s := range p.Subsystems {
+			s.Subvendor = db.Vendors[s.SubvendorID]
+		}
+	}
+	return db
+}
+
+func (c *Compiled) vendor(x int) *types.Vendor {
+	rec := c.rec(tableVendors, x)
+	first, count := c.children(rec)
+	v := &types.Vendor{
+		ID:        hexID(c.id(rec), 4),
+		NumericID: types.VendorID(c.id(rec)),
+		Name:      c.str(rec + 4),
+		Products:  make([]*types.Product, count),
+	}
+	for i := 0; i < count; i++ {
+		v.Products[i] = c.product(first + i)
+		v.Products[i].Vendor = v
+	}
+	return v
+}
+
+func (c *Compiled) product(x int) *types.Product {
+	rec := c.rec(tableProducts, x)
+	first, count := c.children(rec)
+	id := c.id(rec)
+	p := &types.Product{
+		VendorID:        hexID(id>>16, 4),
+		NumericVendorID: types.VendorID(id >> 16),
+		ID:              hexID(id&0xffff, 4),
+		NumericID:       types.DeviceID(id),
+		Name:            c.str(rec + 4),
+		Subsystems:      make([]*types.Subsystem, count),
+	}
+	for i := 0; i < count; i++ {
+		srec := c.rec(tableSubsystems, first+i)
+		sid := c.id(srec)
+		s := &types.Subsystem{
+			SubvendorID:        hexID(sid>>16, 4),
+			NumericSubvendorID: types.VendorID(sid >> 16),
+			SubdeviceID:        hexID(sid&0xffff, 4),
+			NumericSubdeviceID: types.DeviceID(sid),
+			Name:               c.str(srec + 4),
+			Product:            p,
+		}
+		s.VendorID, s.ID = s.SubvendorID, s.SubdeviceID
+		p.Subsystems[i] = s
+	}
+	return p
+}
+
+// linkSubvendors sets the Subvendor of the subsystems of the supplied
+// products. Subvendors other than the supplied vendor are returned without
+// their products, so that looking up one product doesn't materialise every
+// vendor its subsystems refer to.
+func (c *Compiled) linkSubvendors(v *types.Vendor, products ...*types.Product) {
+	for _, p := range products {
+		for _, s := range p.Subsystems {
+			if v != nil && s.NumericSubvendorID == v.NumericID {
+				s.Subvendor = v
+				continue
+			}
+			s.Subvendor = c.vendorHeader(s.NumericSubvendorID)
+		}
+	}
+}
+
+// vendorHeader returns the vendor with the supplied ID, without its products,
+// or nil if there is no such vendor
+func (c *Compiled) vendorHeader(id types.VendorID) *types.Vendor {
+	x, found := c.search(tableVendors, 0, c.counts[tableVendors], uint32(id))
+	if !found {
+		return nil
+	}
+	rec := c.rec(tableVendors, x)
+	return &types.Vendor{
+		ID:        hexID(c.id(rec), 4),
+		NumericID: id,
+		Name:      c.str(rec + 4),
+	}
+}
+
+func (c *Compiled) class(x int) *types.Class {
+	rec := c.rec(tableClasses, x)
+	first, count := c.children(rec)
+	cls := &types.Class{
+		ID:         hexID(c.id(rec), 2),
+		NumericID:  types.ClassID(c.id(rec)),
+		Name:       c.str(rec + 4),
+		Subclasses: make([]*types.Subclass, count),
+	}
+	for i := 0; i < count; i++ {
+		screc := c.rec(tableSubclasses, first+i)
+		pfirst, pcount := c.children(screc)
+		sc := &types.Subclass{
+			ID:                    hexID(c.id(screc), 2),
+			Name:                  c.str(screc + 4),
+			ProgrammingInterfaces: make([]*types.ProgrammingInterface, pcount),
+			Class:                 cls,
+		}
+		for j := 0; j < pcount; j++ {
+			pirec := c.rec(tableProgIfaces, pfirst+j)
+			sc.ProgrammingInterfaces[j] = &types.ProgrammingInterface{
+				ID:       hexID(c.id(pirec), 2),
+				Name:     c.str(pirec + 4),
+				Subclass: sc,
+			}
+		}
+		cls.Subclasses[i] = sc
+	}
+	return cls
+}
+
+// search returns the index of the record with the supplied ID among records
+// [lo, hi) of the supplied table
+func (c *Compiled) search(table int, lo int, hi int, id uint32) (int, bool) {
+	x := lo + sort.Search(hi-lo, func(i int) bool {
+		return c.id(c.rec(table, lo+i)) >= id
+	})
+	return x, x < hi && c.id(c.rec(table, x)) == id
+}
+
+// rec returns the offset of the x'th record in the supplied table
+func (c *Compiled) rec(table int, x int) int {
+	return c.tables[table] + x*tableRecLens[table]
+}
+
+func (c *Compiled) id(rec int) uint32 {
+	return binary.LittleEndian.Uint32(c.data[rec:])
+}
+
+func (c *Compiled) children(rec int) (int, int) {
+	return int(binary.LittleEndian.Uint32(c.data[rec+12:])),
+		int(binary.LittleEndian.Uint32(c.data[rec+16:]))
+}
+
+func (c *Compiled) validRef(ref int) bool {
+	off := int(binary.LittleEndian.Uint32(c.data[ref:]))
+	n := int(binary.LittleEndian.Uint32(c.data[ref+4:]))
+	return off >= 0 && n >= 0 && off+n <= c.poolLen
+}
+
+// str returns a copy of the string referenced at the supplied offset, so
+// that it remains valid after the compiled DB is closed
+func (c *Compiled) str(ref int) string {
+	off := int(binary.LittleEndian.Uint32(c.data[ref:]))
+	n := int(binary.LittleEndian.Uint32(c.data[ref+4:]))
+	if c.names != "" {
+		return c.names[off : off+n]
+	}
+	return string(c.data[c.pool+off : c.pool+off+n])
+}
+
+// parseHexID parses a hex-encoded PCI ID of the supplied bit size
+func parseHexID(id string, bitSize int) (uint32, error) {
+	v, err := strconv.ParseUint(id, 16, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("pcidb: invalid PCI ID %q: %w", id, err)
+	}
+	return uint32(v), nil
+}
+
+// hexID formats a PCI ID as a zero-padded, lowercase hex string of the
+// supplied width
+func hexID(id uint32, width int) string {
+	const digits = "0123456789abcdef"
+	var b [8]byte
+	for x := width - 1; x >= 0; x-- {
+		b[x] = digits[id&0xf]
+		id >>= 4
+	}
+	return string(b[:width])
+}
+
+// compiledPath returns the path that the compiled form of the supplied cache
+// file is stored at
+func compiledPath(cachePath string) string {
+	return cachePath + ".bin"
+}
+
+// loadCompiledCache returns the DB from the compiled cache next to the
+// supplied cache path if it was compiled from the pci-ids DB file described
+// by the supplied path and file info, or nil otherwise.
+func loadCompiledCache(cachePath string, path string, fi os.FileInfo) *types.DB {
+	if cachePath == "" {
+		return nil
+	}
+	c, err := OpenCompiled(compiledPath(cachePath))
+	if err != nil {
+		return nil
+	}
+	defer c.Close()
+	src := c.Source()
+	if src.Path != path || src.Size != fi.Size() || !src.ModTime.Equal(fi.ModTime()) {
+		return nil
+	}
+	return c.DB()
+}
+
+// writeCompiledCache stores the compiled form of the supplied DB, parsed from
+// the pci-ids DB file described by the supplied path and file info, next to
+// the supplied cache path
+func writeCompiledCache(
+	cachePath string,
+	db *types.DB,
+	path string,
+	fi os.FileInfo,
+) error {
+	if cachePath == "" {
+		return types.ErrNoPaths
+	}
+	version := ""
+	if f, err := openDBFile(path); err == nil {
+		version, _ = readHeader(f)
+		f.Close()
+	}
+	var b bytes.Buffer
+	err := WriteCompiled(&b, db, CompiledSource{
+		Version: version,
+		Path:    path,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = writeCacheFile(compiledPath(cachePath), b.Bytes(), false)
+	return err
+}
@@ -0,0 +1,163 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestCompiled(t *testing.T) {
+	f, err := openDBFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error opening fixture, but got %v", err)
+	}
+	db := FromReader(f)
+
+	path := filepath.Join(t.TempDir(), "pci.ids.bin")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating file, but got %v", err)
+	}
+	err = WriteCompiled(out, db, CompiledSource{Version: "2025.08.20"})
+	out.Close()
+	if err != nil {
+		t.Fatalf("Expected no error compiling DB, but got %v", err)
+	}
+
+	c, err := OpenCompiled(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening compiled DB, but got %v", err)
+	}
+	defer c.Close()
+	if c.Source().Version != "2025.08.20" {
+		t.Fatalf("Expected source version 2025.08.20 but got %q", c.Source().Version)
+	}
+
+	megaRaid := c.Product("101e", "1960")
+	if megaRaid == nil || megaRaid.Name != "MegaRAID" {
+		t.Fatalf("Expected to find MegaRAID product, but got %+v", megaRaid)
+	}
+	if len(megaRaid.Subsystems) != 3 || megaRaid.Subsystems[2].Name != "NetRAID-1M" {
+		t.Fatalf("Expected 3 MegaRAID subsystems, but got %+v", megaRaid.Subsystems)
+	}
+	if v := c.Vendor("8086"); v == nil || len(v.Products) != 2 {
+		t.Fatalf("Expected Intel vendor with 2 products, but got %+v", v)
+	}
+	if cls := c.Class("0c"); cls == nil || cls.Subclasses[0].ProgrammingInterfaces[1].Name != "OHCI" {
+		t.Fatalf("Expected serial bus controller class, but got %+v", cls)
+	}
+	if c.Vendor("dead") != nil || c.Product("8086", "zzzz") != nil || c.Class("99") != nil {
+		t.Fatalf("Expected unknown IDs to return nil")
+	}
+
+	// The fixture is sorted, so materialising the compiled DB gives exactly
+	// what parsing the text does
+	want, _ := json.Marshal(db)
+	got, _ := json.Marshal(c.DB())
+	if string(want) != string(got) {
+		t.Fatalf("Expected compiled DB to match parsed DB\nwant: %s\ngot:  %s", want, got)
+	}
+
+	// Materialising the DB doesn't disturb concurrent lookups
+	var wg sync.WaitGroup
+	for x := 0; x < 4; x++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.DB()
+		}()
+		go func() {
+			defer wg.Done()
+			if v := c.Vendor("8086"); v == nil || v.Name != "Intel Corporation" {
+				t.Errorf("Expected Intel vendor, but got %+v", v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt.bin")
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(corrupt, data[:len(data)-1], 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	if _, err := OpenCompiled(corrupt); !errors.Is(err, types.ErrInvalidDB) {
+		t.Fatalf("Expected ErrInvalidDB for truncated file, but got %v", err)
+	}
+}
+
+func TestLoadCompiledCache(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(cachePath, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	opts := MergeOptions(
+		types.WithCachePath(cachePath),
+		types.WithCacheOnly(),
+		types.WithDisableMemoization(),
+	)
+	parsed, err := Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if _, err := os.Stat(compiledPath(cachePath)); err != nil {
+		t.Fatalf("Expected compiled cache to be written, but got %v", err)
+	}
+	compiled, err := Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	want, _ := json.Marshal(parsed)
+	got, _ := json.Marshal(compiled)
+	if string(want) != string(got) {
+		t.Fatalf("Expected DB loaded from compiled cache to match parsed DB")
+	}
+}
+
+func TestCacheCompile(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	cachePath := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(cachePath, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	src, err := NewCache(MergeOptions(types.WithCachePath(cachePath))).Compile()
+	if err != nil {
+		t.Fatalf("Expected no error compiling cache, but got %v", err)
+	}
+	if src != cachePath {
+		t.Fatalf("Expected to compile %s but got %s", cachePath, src)
+	}
+	fi, err := os.Stat(cachePath)
+	if err != nil {
+		t.Fatalf("Expected no error stating cache file, but got %v", err)
+	}
+	// The next load of the cache file uses the compiled cache
+	if db := loadCompiledCache(cachePath, cachePath, fi); db == nil {
+		t.Fatalf("Expected compiled cache to match the cache file")
+	}
+	c, err := OpenCompiled(compiledPath(cachePath))
+	if err != nil {
+		t.Fatalf("Expected no error opening compiled cache, but got %v", err)
+	}
+	defer c.Close()
+	if c.Source().Version != "2025.08.20" {
+		t.Fatalf("Expected version 2025.08.20 but got %q", c.Source().Version)
+	}
+}
@@ -0,0 +1,98 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/internal"
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestDiff(t *testing.T) {
+	parse := func(lines ...string) *types.DB {
+		contents := strings.Join(lines, "\n")
+		return internal.FromReader(io.NopCloser(strings.NewReader(contents)))
+	}
+	oldDB := parse(
+		"1590  HPE",
+		"8086  Intel Corporation",
+		"\t10f8  82599 10 Gigabit Dual Port Backplane Connection",
+		"\t\t8086 000c  Ethernet X520 10GbE Dual Port KX4-KR Mezz",
+		"C 0c  Serial bus controller",
+		"\t03  USB controller",
+		"\t\t30  XHCI",
+	)
+	newDB := parse(
+		"10de  NVIDIA Corporation",
+		"\t2330  GH100 [H100 SXM5 80GB]",
+		"1590  Hewlett Packard Enterprise",
+		"8086  Intel Corporation",
+		"\t10f8  82599 10 Gigabit Dual Port Backplane Connection",
+		"C 0c  Serial bus controller",
+		"\t03  USB controller",
+		"\t\t30  XHCI",
+		"\t\tfe  USB Device",
+	)
+
+	diff := types.Diff(oldDB, newDB)
+	want := []types.Change{
+		{Type: types.ChangeAdded, Kind: types.KindVendor, ID: "10de", NewName: "NVIDIA Corporation"},
+		{Type: types.ChangeAdded, Kind: types.KindProduct, ID: "10de2330", NewName: "GH100 [H100 SXM5 80GB]"},
+		{Type: types.ChangeRenamed, Kind: types.KindVendor, ID: "1590", OldName: "HPE", NewName: "Hewlett Packard Enterprise"},
+		{Type: types.ChangeRemoved, Kind: types.KindSubsystem, ID: "808610f88086000c", OldName: "Ethernet X520 10GbE Dual Port KX4-KR Mezz"},
+		{Type: types.ChangeAdded, Kind: types.KindProgrammingInterface, ID: "0c03fe", NewName: "USB Device"},
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Fatalf("Expected changes %+v but got %+v", want, diff.Changes)
+	}
+	if diff.Count(types.ChangeAdded) != 3 || diff.Count(types.ChangeRemoved) != 1 {
+		t.Fatalf("Expected 3 additions and 1 removal")
+	}
+
+	var text bytes.Buffer
+	if err := diff.WriteText(&text); err != nil {
+		t.Fatalf("Expected no error writing diff, but got %v", err)
+	}
+	wantText := strings.Join([]string{
+		"+ vendor 10de: NVIDIA Corporation",
+		"+ product 10de2330: GH100 [H100 SXM5 80GB]",
+		`~ vendor 1590: "HPE" -> "Hewlett Packard Enterprise"`,
+		"- subsystem 808610f88086000c: Ethernet X520 10GbE Dual Port KX4-KR Mezz",
+		"+ programming interface 0c03fe: USB Device",
+	}, "\n") + "\n"
+	if text.String() != wantText {
+		t.Fatalf("Expected text diff\n%s\nbut got\n%s", wantText, text.String())
+	}
+
+	var out bytes.Buffer
+	if err := diff.WriteJSON(&out); err != nil {
+		t.Fatalf("Expected no error writing diff, but got %v", err)
+	}
+	if !strings.Contains(out.String(), `{"type":"renamed","kind":"vendor","id":"1590","old_name":"HPE",`) {
+		t.Fatalf("Expected change types and kinds by name, but got %s", out.String())
+	}
+	var decoded types.DBDiff
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected no error decoding diff, but got %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Changes, want) {
+		t.Fatalf("Expected decoded changes %+v but got %+v", want, decoded.Changes)
+	}
+
+	if changes := types.Diff(newDB, newDB).Changes; len(changes) != 0 {
+		t.Fatalf("Expected no changes between identical DBs, but got %+v", changes)
+	}
+	if n := types.Diff(nil, newDB).Count(types.ChangeAdded); n != 9 {
+		t.Fatalf("Expected every entry to be added to an empty DB, but got %d", n)
+	}
+}
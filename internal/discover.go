@@ -7,7 +7,9 @@
 package internal
 
 import (
+	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -19,7 +21,6 @@ import (
 )
 
 const (
-	pciidsURI = "https://pci-ids.ucw.cz/v2.2/pci.ids.gz"
 	userAgent = "golang-jaypipes-pcidb"
 )
 
@@ -31,47 +32,129 @@ const (
 // resort and only when network fetching has been enabled with the
 // PCIDB_ENABLE_NETWORK_FETCH=1 environment variable)
 func Discover(opts *types.WithOption) (io.ReadCloser, error) {
-	var foundPath string
-	for _, fp := range searchPaths(opts) {
-		if _, err := os.Stat(fp); err == nil {
-			foundPath = fp
-			break
-		}
+	foundPath, err := resolvePath(opts)
+	if err != nil {
+		return nil, err
+	}
+	return openPath(opts, foundPath)
+}
+
+// openPath returns an io.ReadCloser for the pci-ids DB file at the supplied
+// path, which was returned from resolvePath. If the path is empty, the DB file
+// is fetched from the network, if enabled.
+func openPath(opts *types.WithOption, foundPath string) (io.ReadCloser, error) {
+	checksum := ""
+	if opts.ExpectedChecksum != nil {
+		checksum = *opts.ExpectedChecksum
 	}
 
 	if foundPath == "" {
 		if opts.EnableNetworkFetch != nil && !*opts.EnableNetworkFetch {
 			return nil, types.ErrNoDB
 		}
-		var cachePath = types.DefaultCachePath
-		if opts.CachePath != nil && *opts.CachePath != "" {
-			cachePath = *opts.CachePath
+		// OK, so we didn't find any host-local copy of the pci-ids DB file.
+		// Let's try fetching it from the network and storing it
+		url := fetchURL(opts)
+		data, err := fetchDBFile(url)
+		if err != nil {
+			return nil, err
 		}
-		if cachePath == "" {
-			return nil, types.ErrNoPaths
+		// Verify the fetched copy before caching it so that a mismatched
+		// download never replaces the cache file
+		if checksum != "" {
+			err := verifyChecksum(url, bytes.NewReader(data), checksum)
+			if err != nil {
+				return nil, err
+			}
+		}
+		entry, err := NewCache(opts).save(data)
+		if err != nil {
+			// The cache may legitimately be unwritable, for instance on a
+			// read-only root filesystem or when $HOME is unset, so rather
+			// than failing we use the fetched copy straight from memory.
+			alerter(opts).Printf(
+				"pcidb: unable to cache pci-ids DB file fetched from %s, "+
+					"using in-memory copy: %v",
+				url, err,
+			)
+			return io.NopCloser(bytes.NewReader(data)), nil
 		}
-		// OK, so we didn't find any host-local copy of the pci-ids DB file. Let's
-		// try fetching it from the network and storing it
-		if err := cacheDBFile(cachePath); err != nil {
+		return openDBFile(entry.Path)
+	}
+	if checksum != "" {
+		if err := verifyFileChecksum(foundPath, checksum); err != nil {
 			return nil, err
 		}
-		foundPath = cachePath
 	}
-	f, err := os.Open(foundPath)
+	return openDBFile(foundPath)
+}
+
+// resolvePath returns the path of the host-local pci-ids DB file to use, or
+// an empty string if none could be found.
+func resolvePath(opts *types.WithOption) (string, error) {
+	if opts.Version != nil && *opts.Version != "" {
+		checksum := ""
+		if opts.ExpectedChecksum != nil {
+			checksum = *opts.ExpectedChecksum
+		}
+		entry, err := NewCache(opts).Find(*opts.Version, checksum)
+		if err != nil {
+			return "", err
+		}
+		return entry.Path, nil
+	}
+
+	for _, fp := range searchPaths(opts) {
+		if _, err := os.Stat(fp); err == nil {
+			return fp, nil
+		}
+	}
+	return "", nil
+}
+
+// openDBFile opens the pci-ids DB file at the supplied path, transparently
+// decompressing the contents if the path has a ".gz" suffix
+func openDBFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if strings.HasSuffix(foundPath, ".gz") {
-		var zipReader *gzip.Reader
-		if zipReader, err = gzip.NewReader(f); err != nil {
+	if strings.HasSuffix(path, ".gz") {
+		zipReader, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
 			return nil, err
 		}
-		return zipReader, nil
+		return &gzipFile{Reader: zipReader, f: f}, nil
 	}
 	return f, nil
 }
 
+// gzipFile closes both the gzip stream and the underlying file
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+func cacheCompress(opts *types.WithOption) bool {
+	return opts.CacheCompress != nil && *opts.CacheCompress
+}
+
+// compressedCachePath returns the path that a gzip-compressed copy of the
+// supplied cache file path is stored at
+func compressedCachePath(cachePath string) string {
+	if strings.HasSuffix(cachePath, ".gz") {
+		return cachePath
+	}
+	return cachePath + ".gz"
+}
+
 // Depending on the operating system, sets the context's searchPaths to a set
 // of local filepaths to search for a pci.ids database file
 func searchPaths(opts *types.WithOption) []string {
@@ -88,6 +171,9 @@ func searchPaths(opts *types.WithOption) []string {
 		cachePath = *opts.CachePath
 	}
 	paths = append(paths, cachePath)
+	if cachePath != "" && !strings.HasSuffix(cachePath, ".gz") {
+		paths = append(paths, compressedCachePath(cachePath))
+	}
 	if opts.CacheOnly != nil && *opts.CacheOnly {
 		return paths
 	}
@@ -121,7 +207,7 @@ func searchPaths(opts *types.WithOption) []string {
 func ensureDir(fp string) error {
 	fpDir := filepath.Dir(fp)
 	if _, err := os.Stat(fpDir); os.IsNotExist(err) {
-		err = os.MkdirAll(fpDir, os.ModePerm)
+		err = os.MkdirAll(fpDir, types.DefaultCacheDirMode)
 		if err != nil {
 			return err
 		}
@@ -129,40 +215,92 @@ func ensureDir(fp string) error {
 	return nil
 }
 
-// Pulls down the latest copy of the pci-ids file from the network and stores
-// it in the local host filesystem
-func cacheDBFile(cacheFilePath string) error {
-	ensureDir(cacheFilePath)
+// fetchURL returns the URL that the pci-ids DB file is fetched from for the
+// supplied options
+func fetchURL(opts *types.WithOption) string {
+	if opts.FetchURL != nil && *opts.FetchURL != "" {
+		return *opts.FetchURL
+	}
+	return types.DefaultFetchURL
+}
 
+// Pulls down the latest copy of the gzip-compressed pci-ids file from the
+// supplied URL and returns its uncompressed contents
+func fetchDBFile(url string) ([]byte, error) {
 	client := new(http.Client)
-	request, err := http.NewRequest("GET", pciidsURI, nil)
+	request, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	request.Header.Set("User-Agent", userAgent)
 	response, err := client.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer response.Body.Close()
-	f, err := os.Create(cacheFilePath)
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"pcidb: failed fetching %s: %s", url, response.Status,
+		)
+	}
+	zr, err := gzip.NewReader(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// Stores the supplied pci-ids DB file contents in the local host filesystem,
+// returning the path of the cached file.
+//
+// The file is written to a temporary file in the cache directory and renamed
+// into place so that concurrent readers of a shared cache never see a
+// partially-written database file.
+func writeCacheFile(
+	cacheFilePath string,
+	data []byte,
+	compress bool,
+) (path string, err error) {
+	if err := ensureDir(cacheFilePath); err != nil {
+		return "", err
+	}
+	if compress {
+		cacheFilePath = compressedCachePath(cacheFilePath)
+	}
+	f, err := os.CreateTemp(
+		filepath.Dir(cacheFilePath), "."+filepath.Base(cacheFilePath)+".*",
+	)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer func() {
 		if err != nil {
-			os.Remove(cacheFilePath)
+			os.Remove(f.Name())
 		}
 	}()
 	defer f.Close()
-	// write the gunzipped contents to our local cache file
-	zr, err := gzip.NewReader(response.Body)
-	if err != nil {
-		return err
+	if compress {
+		zw := gzip.NewWriter(f)
+		if _, err = zw.Write(data); err != nil {
+			return "", err
+		}
+		if err = zw.Close(); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err = f.Write(data); err != nil {
+			return "", err
+		}
 	}
-	defer zr.Close()
-	if _, err = io.Copy(f, zr); err != nil {
-		return err
+	if err = f.Chmod(types.DefaultCacheFileMode); err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(f.Name(), cacheFilePath); err != nil {
+		return "", err
 	}
-	return err
+	return cacheFilePath, nil
 }
@@ -0,0 +1,114 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+type recordingAlerter struct {
+	alerts []string
+}
+
+func (a *recordingAlerter) Printf(format string, v ...interface{}) {
+	a.alerts = append(a.alerts, fmt.Sprintf(format, v...))
+}
+
+func TestDiscoverUnwritableCache(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			zw := gzip.NewWriter(w)
+			zw.Write(contents)
+			zw.Close()
+		},
+	))
+	defer srv.Close()
+
+	// A cache path whose parent is a regular file can never be written,
+	// even when running as root
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	for _, cachePath := range []string{filepath.Join(blocker, "pci.ids"), ""} {
+		alerter := &recordingAlerter{}
+		opts := MergeOptions(
+			types.WithCachePath(cachePath),
+			types.WithCacheOnly(),
+			types.WithEnableNetworkFetch(),
+			types.WithFetchURL(srv.URL),
+			types.WithAlerter(alerter),
+		)
+		if cachePath == "" {
+			// WithCachePath("") is merged as-is but falls back to the
+			// default cache path, so point that somewhere unwritable too
+			origDefault := types.DefaultCachePath
+			types.DefaultCachePath = ""
+			defer func() { types.DefaultCachePath = origDefault }()
+		}
+		f, err := Discover(opts)
+		if err != nil {
+			t.Fatalf("Expected no error for cache path %q, but got %v", cachePath, err)
+		}
+		db := FromReader(f)
+		if _, exists := db.Vendors["8086"]; !exists {
+			t.Fatalf("Expected to find Intel vendor in fetched DB")
+		}
+		if len(alerter.alerts) != 1 {
+			t.Fatalf("Expected a single warning, but got %v", alerter.alerts)
+		}
+	}
+}
+
+func TestDiscoverFetchChecksumMismatch(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			zw := gzip.NewWriter(w)
+			zw.Write(contents)
+			zw.Close()
+		},
+	))
+	defer srv.Close()
+
+	cachePath := filepath.Join(t.TempDir(), "pci.ids")
+	_, err = Discover(MergeOptions(
+		types.WithCachePath(cachePath),
+		types.WithCacheOnly(),
+		types.WithEnableNetworkFetch(),
+		types.WithFetchURL(srv.URL),
+		types.WithExpectedChecksum(strings.Repeat("0", 64)),
+	))
+	if !errors.Is(err, types.ErrChecksumMismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch but got %v", err)
+	}
+	// The mismatched download is never cached
+	entries, err := NewCache(MergeOptions(types.WithCachePath(cachePath))).List()
+	if err != nil {
+		t.Fatalf("Expected no error listing cache, but got %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Expected an empty cache, but got %+v", entries)
+	}
+}
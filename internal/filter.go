@@ -0,0 +1,124 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// filter restricts the entries that are parsed from a pci-ids DB file, and
+// selects whether the comments attached to them are kept. A nil *filter
+// parses every entry and no comments.
+type filter struct {
+	vendors        map[string]bool // nil means all vendors
+	classes        map[string]bool // nil means all classes
+	skipSubsystems bool
+	comments       bool
+}
+
+// newFilter returns the filter described by the supplied options, or nil if
+// the options don't restrict what is parsed
+func newFilter(opts *types.WithOption) *filter {
+	f := &filter{
+		vendors: idSet(opts.Vendors),
+		classes: idSet(opts.Classes),
+	}
+	if opts.SkipSubsystems != nil {
+		f.skipSubsystems = *opts.SkipSubsystems
+	}
+	if opts.Comments != nil {
+		f.comments = *opts.Comments
+	}
+	if f.vendors == nil && f.classes == nil && !f.skipSubsystems && !f.comments {
+		return nil
+	}
+	return f
+}
+
+// idSet returns the set of the supplied hex-encoded IDs, lowercased to match
+// the pci-ids DB file, or nil if ids is nil
+func idSet(ids []string) map[string]bool {
+	if ids == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[strings.ToLower(id)] = true
+	}
+	return set
+}
+
+// apply returns the kind the parser should treat the supplied line as, given
+// the kind classify returned for it, and whether the lines that follow belong
+// to a block that is being skipped. Lines in skipped blocks are kindNone, and
+// the header line of a skipped block is kindSkipped so that the parser still
+// finishes the preceding block.
+func (f *filter) apply(
+	kind entryKind,
+	line string,
+	skipping bool,
+) (entryKind, bool) {
+	if f == nil {
+		return kind, false
+	}
+	switch kind {
+	case kindVendor:
+		if f.vendors != nil && !f.vendors[line[0:4]] {
+			return kindSkipped, true
+		}
+		return kind, false
+	case kindClass:
+		if f.classes != nil && !f.classes[line[2:4]] {
+			return kindSkipped, true
+		}
+		return kind, false
+	case kindSubsystem:
+		if f.skipSubsystems {
+			return kindNone, skipping
+		}
+	}
+	if skipping {
+		return kindNone, skipping
+	}
+	return kind, skipping
+}
+
+// keepComments returns whether comments attached to entries are kept
+func (f *filter) keepComments() bool {
+	return f != nil && f.comments
+}
+
+// key returns a string that is equal for equivalent filters, for use in memo
+// keys
+func (f *filter) key() string {
+	if f == nil {
+		return ""
+	}
+	key := setKey(f.vendors) + "|" + setKey(f.classes)
+	if f.skipSubsystems {
+		key += "|nosubsystems"
+	}
+	if f.comments {
+		key += "|comments"
+	}
+	return key
+}
+
+func setKey(set map[string]bool) string {
+	if set == nil {
+		return "*"
+	}
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,135 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"unsafe"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestFilter(t *testing.T) {
+	fixture := basicFixture
+	full, err := Load(MergeOptions(
+		types.WithPath(fixture), types.WithDisableMemoization(),
+	))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+
+	opts := MergeOptions(
+		types.WithPath(fixture),
+		types.WithVendors("101E", "8086", "dead"),
+		types.WithClasses("0c"),
+		types.WithoutSubsystems(),
+	)
+	db, err := Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if len(db.Vendors) != 2 || db.Vendors["101e"] == nil || db.Vendors["8086"] == nil {
+		t.Fatalf("Expected only vendors 101e and 8086, but got %v", db.Vendors)
+	}
+	if len(db.Products) != 3 {
+		t.Fatalf("Expected 3 products, but got %d", len(db.Products))
+	}
+	for key, p := range db.Products {
+		if len(p.Subsystems) != 0 {
+			t.Fatalf("Expected no subsystems for product %s, but got %v", key, p.Subsystems)
+		}
+	}
+	if len(db.Vendors["8086"].Products) != 2 {
+		t.Fatalf("Expected 2 products for vendor 8086, but got %d", len(db.Vendors["8086"].Products))
+	}
+	if len(db.Classes) != 1 || db.Classes["0c"] == nil {
+		t.Fatalf("Expected only class 0c, but got %v", db.Classes)
+	}
+	if len(db.Classes["0c"].Subclasses) != 2 {
+		t.Fatalf("Expected 2 subclasses for class 0c, but got %d", len(db.Classes["0c"].Subclasses))
+	}
+	if db == full {
+		t.Fatalf("Expected filtered and unfiltered loads not to share a DB")
+	}
+	again, err := Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if again != db {
+		t.Fatalf("Expected equivalently filtered loads to share a single DB")
+	}
+
+	lazy, err := LoadLazy(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	want, _ := json.Marshal(db)
+	got, _ := json.Marshal(lazy.DB())
+	if string(want) != string(got) {
+		t.Fatalf("Expected filtered lazy DB to match filtered DB\nwant: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestFilterCopiesKeptText(t *testing.T) {
+	data, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	text := string(data)
+	start := uintptr(unsafe.Pointer(unsafe.StringData(text)))
+	end := start + uintptr(len(text))
+	inText := func(s string) bool {
+		p := uintptr(unsafe.Pointer(unsafe.StringData(s)))
+		return s != "" && p >= start && p < end
+	}
+
+	if db := parseText(text, nil); !inText(db.Vendors["8086"].Name) {
+		t.Fatalf("Expected unfiltered names to be substrings of the file's contents")
+	}
+
+	flt := newFilter(MergeOptions(
+		types.WithVendors("15b3", "8086"),
+		types.WithClasses("0c"),
+		types.WithComments(),
+	))
+	db := parseText(text, flt)
+	var strs []string
+	for id, v := range db.Vendors {
+		strs = append(strs, id, v.ID, v.Name)
+		strs = append(strs, v.Comments...)
+		for _, p := range v.Products {
+			strs = append(strs, p.ID, p.VendorID, p.Name)
+			strs = append(strs, p.Comments...)
+			for _, s := range p.Subsystems {
+				strs = append(strs, s.SubvendorID, s.SubdeviceID, s.Name)
+				strs = append(strs, s.Comments...)
+			}
+		}
+	}
+	for id, c := range db.Classes {
+		strs = append(strs, id, c.ID, c.Name)
+		for _, sc := range c.Subclasses {
+			strs = append(strs, sc.ID, sc.Name)
+			for _, pi := range sc.ProgrammingInterfaces {
+				strs = append(strs, pi.ID, pi.Name)
+			}
+		}
+	}
+	for key := range db.Subsystems {
+		strs = append(strs, key)
+	}
+	if len(db.Products["15b3101d"].Comments) == 0 {
+		t.Fatalf("Expected the comment attached to product 15b3101d to be kept")
+	}
+	for _, s := range strs {
+		if inText(s) {
+			t.Fatalf("Expected filtered DB not to hold the file's contents, but %q is a substring of it", s)
+		}
+	}
+}
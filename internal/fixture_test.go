@@ -0,0 +1,15 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import "path/filepath"
+
+// basicFixture is the path of the pci-ids DB file that most tests load. It is
+// the file embedded as pcidbtest.Basic, read from its place in the source
+// tree, since pcidbtest imports this package and so can't be imported by its
+// tests.
+var basicFixture = filepath.Join("..", "pcidbtest", "fixtures", "basic.ids")
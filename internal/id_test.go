@@ -0,0 +1,128 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestParseIDs(t *testing.T) {
+	for _, s := range []string{"8086", "0x8086", "0X8086\n", " 8086 "} {
+		id, err := types.ParseVendorID(s)
+		if err != nil {
+			t.Fatalf("Expected no error parsing %q, but got %v", s, err)
+		}
+		if id != 0x8086 {
+			t.Fatalf("Expected 0x8086 parsing %q, but got %#x", s, uint16(id))
+		}
+	}
+	for _, s := range []string{"", "0x", "808g", "18086"} {
+		if _, err := types.ParseDeviceID(s); !errors.Is(err, types.ErrInvalidID) {
+			t.Fatalf("Expected ErrInvalidID parsing %q, but got %v", s, err)
+		}
+	}
+	if _, err := types.ParseClassID("102"); !errors.Is(err, types.ErrInvalidID) {
+		t.Fatalf("Expected ErrInvalidID parsing an oversized class ID, but got %v", err)
+	}
+
+	if s := types.VendorID(0x10de).String(); s != "10de" {
+		t.Fatalf("Expected 10de but got %s", s)
+	}
+	if s := types.DeviceID(0x1).String(); s != "0001" {
+		t.Fatalf("Expected 0001 but got %s", s)
+	}
+	if s := types.ClassID(0xc).String(); s != "0c" {
+		t.Fatalf("Expected 0c but got %s", s)
+	}
+
+	ids := struct {
+		Vendor types.VendorID `json:"vendor"`
+		Device types.DeviceID `json:"device"`
+		Class  types.ClassID  `json:"class"`
+	}{0x8086, 0x10f8, 0x2}
+	b, err := json.Marshal(ids)
+	if err != nil {
+		t.Fatalf("Expected no error marshaling IDs, but got %v", err)
+	}
+	if string(b) != `{"vendor":"8086","device":"10f8","class":"02"}` {
+		t.Fatalf("Expected IDs to marshal as hex strings, but got %s", b)
+	}
+	ids.Vendor, ids.Device, ids.Class = 0, 0, 0
+	if err := json.Unmarshal(b, &ids); err != nil {
+		t.Fatalf("Expected no error unmarshaling IDs, but got %v", err)
+	}
+	if ids.Vendor != 0x8086 || ids.Device != 0x10f8 || ids.Class != 0x2 {
+		t.Fatalf("Expected IDs to round-trip, but got %+v", ids)
+	}
+}
+
+func TestLookupByID(t *testing.T) {
+	fixture := basicFixture
+	db, err := Load(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	product := db.ProductByID(0x8086, 0x10f8)
+	if product == nil || product != db.Products["808610f8"] {
+		t.Fatalf("Expected to find product 8086:10f8, but got %+v", product)
+	}
+	if product.NumericVendorID != 0x8086 || product.NumericID != 0x10f8 {
+		t.Fatalf("Expected numeric IDs 8086:10f8, but got %+v", product)
+	}
+	subsystem := product.Subsystems[0]
+	if subsystem.NumericSubvendorID != 0x1028 || subsystem.NumericSubdeviceID != 0x1f63 {
+		t.Fatalf("Expected numeric subsystem IDs 1028:1f63, but got %+v", subsystem)
+	}
+	if v := db.VendorByID(0x10de); v == nil || v.NumericID != 0x10de {
+		t.Fatalf("Expected to find vendor 10de, but got %+v", v)
+	}
+	if cls := db.ClassByID(0xc); cls == nil || cls.NumericID != 0xc {
+		t.Fatalf("Expected to find class 0c, but got %+v", cls)
+	}
+	if db.VendorByID(0xdead) != nil {
+		t.Fatalf("Expected unknown vendor to return nil")
+	}
+
+	path := filepath.Join(t.TempDir(), "pci.ids.bin")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating file, but got %v", err)
+	}
+	err = WriteCompiled(out, db, CompiledSource{})
+	out.Close()
+	if err != nil {
+		t.Fatalf("Expected no error compiling DB, but got %v", err)
+	}
+	c, err := OpenCompiled(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening compiled DB, but got %v", err)
+	}
+	defer c.Close()
+	lazy, err := LoadLazy(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	for _, p := range []*types.Product{
+		c.ProductByID(0x8086, 0x10f8), lazy.ProductByID(0x8086, 0x10f8),
+	} {
+		if p == nil || p.NumericVendorID != 0x8086 || p.NumericID != 0x10f8 {
+			t.Fatalf("Expected to find product 8086:10f8, but got %+v", p)
+		}
+	}
+	if v := c.VendorByID(0x10de); v == nil || v.NumericID != 0x10de {
+		t.Fatalf("Expected to find compiled vendor 10de, but got %+v", v)
+	}
+	if cls := lazy.ClassByID(0x1); cls == nil || cls.NumericID != 0x1 {
+		t.Fatalf("Expected to find lazy class 01, but got %+v", cls)
+	}
+}
@@ -0,0 +1,71 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/internal"
+	"github.com/jaypipes/pcidb/types"
+)
+
+// benchmarkSuggest benchmarks each of the supplied queries against an index
+// of the supplied pci-ids DB file contents
+func benchmarkSuggest(b *testing.B, contents string, queries []string) {
+	db := internal.FromReader(io.NopCloser(strings.NewReader(contents)))
+	idx := types.NewIndex(db)
+	for _, query := range queries {
+		b.Run(query, func(b *testing.B) {
+			b.ReportAllocs()
+			for x := 0; x < b.N; x++ {
+				idx.Suggest(query, 10)
+			}
+		})
+	}
+}
+
+func BenchmarkNewIndexSynthetic(b *testing.B) {
+	db := internal.FromReader(io.NopCloser(strings.NewReader(syntheticDB())))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		types.NewIndex(db)
+	}
+}
+
+func BenchmarkSuggestSynthetic(b *testing.B) {
+	benchmarkSuggest(b, syntheticDB(), []string{
+		"vendor number 1234",
+		"famly 1234",
+		"product 7 fam",
+		"corporation",
+		"c",
+	})
+}
+
+// BenchmarkSuggestHost benchmarks suggestions from the host's pci.ids
+// database file, if one can be found
+func BenchmarkSuggestHost(b *testing.B) {
+	f, err := internal.Discover(internal.MergeOptions())
+	if err != nil {
+		b.Skipf("Skipping, no host pci.ids database file: %v", err)
+	}
+	contents, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		b.Fatalf("Expected no error reading pci.ids, but got %v", err)
+	}
+	benchmarkSuggest(b, string(contents), []string{
+		"intel",
+		"connectx 6",
+		"mellanx",
+		"geforce rtx 40",
+		"e",
+	})
+}
@@ -0,0 +1,89 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestIndexSuggest(t *testing.T) {
+	db, err := Load(MergeOptions(types.WithPath(basicFixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	idx := types.NewIndex(db)
+
+	ids := func(suggestions []types.Suggestion) []string {
+		var ids []string
+		for _, s := range suggestions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// The last word is completed as it is typed
+		{"mell", []string{"15b3", "1028"}},
+		{"connectx 6 d", []string{"15b3101d"}},
+		// Ties are broken by the shorter name
+		{"hewlett", []string{"103c", "1590"}},
+		// Typos are tolerated, and names the query covers more of rank first
+		{"coporation", []string{"8086", "10de", "0e11"}},
+		{"conectx-6", []string{"15b3101b", "15b3101d"}},
+		{"raid", []string{"1028" + "0001"}},
+		{"nothing matches", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		got := ids(idx.Suggest(test.query, 0))
+		if len(got) != len(test.want) {
+			t.Fatalf("Expected suggestions %v for %q but got %v", test.want, test.query, got)
+		}
+		for x := range got {
+			if got[x] != test.want[x] {
+				t.Fatalf("Expected suggestions %v for %q but got %v", test.want, test.query, got)
+			}
+		}
+	}
+
+	suggestions := idx.Suggest("intel corp", 1)
+	if len(suggestions) != 1 || suggestions[0].Score >= 1 {
+		t.Fatalf("Expected a score below 1 for a completed word, but got %+v", suggestions)
+	}
+	suggestions = idx.Suggest("INTEL corporation", 1)
+	if len(suggestions) != 1 || suggestions[0].Vendor != db.Vendors["8086"] {
+		t.Fatalf("Expected Intel as the only suggestion, but got %+v", suggestions)
+	}
+	if suggestions[0].Score != 1 {
+		t.Fatalf("Expected a score of 1 for a complete match, but got %v", suggestions[0].Score)
+	}
+	spans := suggestions[0].Spans
+	if len(spans) != 2 || suggestions[0].Name[spans[1][0]:spans[1][1]] != "Corporation" {
+		t.Fatalf("Expected spans of both words, but got %v", spans)
+	}
+	// "Connection" starts with a word one typo away from "connectx"
+	suggestions = idx.Suggest("connectx", 0)
+	if len(suggestions) != 3 || suggestions[0].Product != db.Products["15b3101b"] ||
+		suggestions[2].ID != "808610f8" {
+		t.Fatalf("Expected ConnectX-6 products then the fuzzy match, but got %+v", suggestions)
+	}
+}
+
+func TestIndexSuggestLongWord(t *testing.T) {
+	// The query word shares more than 255 trigrams with the name
+	long := strings.Repeat("a", 300)
+	db := parseText("1234  "+long+"\n", nil)
+	got := types.NewIndex(db).Suggest(long+"x", 0)
+	if len(got) != 1 || got[0].ID != "1234" {
+		t.Fatalf("Expected a fuzzy suggestion of vendor 1234, but got %+v", got)
+	}
+}
@@ -0,0 +1,63 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestSortedIterators(t *testing.T) {
+	db, err := Load(MergeOptions(types.WithPath(basicFixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+
+	var vendorIDs []string
+	for v := range db.AllVendors() {
+		vendorIDs = append(vendorIDs, v.ID)
+	}
+	want := "0e11 101e 1028 103c 10de 1590 15b3 8086"
+	if got := strings.Join(vendorIDs, " "); got != want {
+		t.Fatalf("Expected vendors %s but got %s", want, got)
+	}
+
+	var productKeys []string
+	hasProducts := func(v *types.Vendor) bool { return len(v.Products) > 0 }
+	for v := range db.AllVendors(hasProducts) {
+		for p := range db.AllProducts(func(p *types.Product) bool { return p.Vendor == v }) {
+			productKeys = append(productKeys, p.VendorID+p.ID)
+		}
+	}
+	want = "0e110001 0e114091 101e1960 10280001 103c1030 10de2330 15b3101b 15b3101d 808610f8 80861572"
+	if got := strings.Join(productKeys, " "); got != want {
+		t.Fatalf("Expected products %s but got %s", want, got)
+	}
+
+	var subsystems []string
+	dell := func(s *types.Subsystem) bool { return s.SubvendorID == "1028" }
+	for p, s := range db.AllSubsystems(dell) {
+		subsystems = append(subsystems, p.VendorID+p.ID+s.SubvendorID+s.SubdeviceID)
+		if len(subsystems) == 3 {
+			break
+		}
+	}
+	want = "101e196010280471 1028000110280001 15b3101d10280009"
+	if got := strings.Join(subsystems, " "); got != want {
+		t.Fatalf("Expected subsystems %s but got %s", want, got)
+	}
+
+	var classIDs []string
+	for c := range db.AllClasses() {
+		classIDs = append(classIDs, c.ID)
+	}
+	if got := strings.Join(classIDs, " "); got != "01 02 0c" {
+		t.Fatalf("Expected classes 01 02 0c but got %s", got)
+	}
+}
@@ -0,0 +1,153 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// JSONSchemaVersion is the version of the JSON encoding of a DB that
+// WriteJSON writes and the highest version FromJSON reads. It is incremented
+// whenever the encoding changes in a way that older readers would misread.
+const JSONSchemaVersion = 1
+
+// jsonDB is the JSON encoding of a DB written by WriteJSON: the DB's own
+// fields, as encoded by encoding/json, and the version of the encoding
+type jsonDB struct {
+	SchemaVersion int `json:"schema_version"`
+	*types.DB
+}
+
+// WriteJSON writes the supplied DB as a JSON document in version
+// JSONSchemaVersion of the pcidb JSON schema, which FromJSON reads back.
+//
+// The document is the JSON encoding of the DB, with its "classes", "vendors"
+// and "products" maps, plus a "schema_version" field. Keys of the "products"
+// map are the vendor ID followed by the product ID, for example "808610f8".
+func WriteJSON(w io.Writer, db *types.DB) error {
+	return json.NewEncoder(w).Encode(jsonDB{SchemaVersion: JSONSchemaVersion, DB: db})
+}
+
+// FromJSON reads a DB from a JSON document written by WriteJSON, or by
+// encoding a DB with encoding/json, which is read as version 1 of the pcidb
+// JSON schema.
+//
+// Only the "vendors" and "classes" maps and the "layers" list are read. The
+// "products" map, the other flat maps, the numeric IDs and the pointers
+// between entries are all rebuilt from them, so the returned DB is the same
+// as the DB that was written. An error wrapping types.ErrInvalidDB is
+// returned if the document has a newer schema version than
+// JSONSchemaVersion or has an invalid entry.
+func FromJSON(r io.Reader) (*types.DB, error) {
+	var doc struct {
+		SchemaVersion int                      `json:"schema_version"`
+		Vendors       map[string]*types.Vendor `json:"vendors"`
+		Classes       map[string]*types.Class  `json:"classes"`
+		Layers        []string                 `json:"layers"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", types.ErrInvalidDB, err)
+	}
+	if doc.SchemaVersion > JSONSchemaVersion {
+		return nil, fmt.Errorf(
+			"%w: JSON schema version %d is newer than the supported version %d",
+			types.ErrInvalidDB, doc.SchemaVersion, JSONSchemaVersion,
+		)
+	}
+	db := &types.DB{Vendors: doc.Vendors, Classes: doc.Classes, Layers: doc.Layers}
+	if db.Vendors == nil {
+		db.Vendors = map[string]*types.Vendor{}
+	}
+	if db.Classes == nil {
+		db.Classes = map[string]*types.Class{}
+	}
+	if err := linkVendors(db.Vendors); err != nil {
+		return nil, err
+	}
+	if err := linkClasses(db.Classes); err != nil {
+		return nil, err
+	}
+	indexDB(db)
+	for _, s := range db.Subsystems {
+		s.Subvendor = db.Vendors[s.SubvendorID]
+	}
+	return db, nil
+}
+
+// linkVendors checks the IDs of the supplied decoded vendors and their
+// products and subsystems, and sets the fields that aren't encoded
+func linkVendors(vendors map[string]*types.Vendor) error {
+	for key, v := range vendors {
+		if v == nil || v.ID != key || !isHexID(v.ID, 4) {
+			return invalidEntry("vendor", key)
+		}
+		v.NumericID = types.VendorID(hexValue(v.ID))
+		for _, p := range v.Products {
+			if p == nil || !isHexID(p.ID, 4) {
+				return invalidEntry("product of vendor", key)
+			}
+			p.VendorID, p.NumericVendorID, p.Vendor = v.ID, v.NumericID, v
+			p.NumericID = types.DeviceID(hexValue(p.ID))
+			for _, s := range p.Subsystems {
+				if s == nil || !isHexID(s.SubvendorID, 4) || !isHexID(s.SubdeviceID, 4) {
+					return invalidEntry("subsystem of product", key+p.ID)
+				}
+				s.Product = p
+			}
+		}
+	}
+	return nil
+}
+
+// linkClasses checks the IDs of the supplied decoded classes and their
+// subclasses and programming interfaces, and sets the fields that aren't
+// encoded
+func linkClasses(classes map[string]*types.Class) error {
+	for key, c := range classes {
+		if c == nil || c.ID != key || !isHexID(c.ID, 2) {
+			return invalidEntry("class", key)
+		}
+		c.NumericID = types.ClassID(hexValue(c.ID))
+		for _, sc := range c.Subclasses {
+			if sc == nil || !isHexID(sc.ID, 2) {
+				return invalidEntry("subclass of class", key)
+			}
+			sc.Class = c
+			for _, pi := range sc.ProgrammingInterfaces {
+				if pi == nil || !isHexID(pi.ID, 2) {
+					return invalidEntry("programming interface of subclass", key+sc.ID)
+				}
+				pi.Subclass = sc
+			}
+		}
+	}
+	return nil
+}
+
+// isHexID returns whether the supplied ID is the supplied number of lowercase
+// hex digits, as IDs are in the pci-ids DB file
+func isHexID(id string, width int) bool {
+	if len(id) != width {
+		return false
+	}
+	for x := 0; x < len(id); x++ {
+		if c := id[x]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidEntry returns an error wrapping types.ErrInvalidDB for a decoded
+// entry, described by the supplied kind and key, with a missing or invalid ID
+func invalidEntry(kind string, key string) error {
+	return fmt.Errorf("%w: invalid %s %q in JSON document", types.ErrInvalidDB, kind, key)
+}
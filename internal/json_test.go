@@ -0,0 +1,128 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	db, err := Load(MergeOptions(
+		types.WithPath(basicFixture),
+		types.WithComments(),
+		types.WithDisableMemoization(),
+	))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	var out bytes.Buffer
+	if err := WriteJSON(&out, db); err != nil {
+		t.Fatalf("Expected no error writing JSON, but got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), `{"schema_version":1,"classes":{`) {
+		t.Fatalf("Expected schema version first, but got %.60s", out.String())
+	}
+	loaded, err := FromJSON(&out)
+	if err != nil {
+		t.Fatalf("Expected no error reading JSON, but got %v", err)
+	}
+	want, _ := json.Marshal(db)
+	got, _ := json.Marshal(loaded)
+	if string(want) != string(got) {
+		t.Fatalf("Expected DB read from JSON to match parsed DB\nwant: %s\ngot:  %s", want, got)
+	}
+
+	megaRaid := loaded.Products["101e1960"]
+	if megaRaid == nil || megaRaid.Vendor != loaded.Vendors["101e"] || megaRaid.NumericID != 0x1960 {
+		t.Fatalf("Expected MegaRAID linked to its vendor, but got %+v", megaRaid)
+	}
+	netRaid := loaded.Subsystems["101e1960103c60e7"]
+	if netRaid == nil || netRaid.Product != megaRaid || netRaid.Subvendor != loaded.Vendors["103c"] {
+		t.Fatalf("Expected NetRAID-1M linked to its product and subvendor, but got %+v", netRaid)
+	}
+	nvme := loaded.ProgrammingInterfaces["010802"]
+	if nvme == nil || nvme.Subclass != loaded.Subclasses["0108"] || nvme.Subclass.Class != loaded.Classes["01"] {
+		t.Fatalf("Expected NVM Express linked to its subclass and class, but got %+v", nvme)
+	}
+	if len(loaded.SubsystemsBySubvendor("1028")) != len(db.SubsystemsBySubvendor("1028")) {
+		t.Fatalf("Expected subvendor index to be rebuilt")
+	}
+	if got := loaded.Products["15b3101d"].Comments; len(got) != 1 {
+		t.Fatalf("Expected product comments to be read, but got %q", got)
+	}
+
+	// A DB encoded directly with encoding/json is read as version 1
+	loaded, err = FromJSON(bytes.NewReader(want))
+	if err != nil {
+		t.Fatalf("Expected no error reading encoded DB, but got %v", err)
+	}
+	if got, _ := json.Marshal(loaded); string(got) != string(want) {
+		t.Fatalf("Expected DB read from encoded DB to match parsed DB")
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	legacy := `{"vendors": {"101e": {"id": "101e", "name": "American Megatrends Inc.", "products": [
+		{"id": "1960", "name": "MegaRAID", "subsystems": [{"vendor_id": "103c", "id": "60e7", "name": "NetRAID-1M"}]}
+	]}}}`
+	db, err := FromJSON(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatalf("Expected no error reading legacy subsystems, but got %v", err)
+	}
+	if s := db.Subsystems["101e1960103c60e7"]; s == nil || s.SubvendorID != "103c" || s.Subvendor != nil {
+		t.Fatalf("Expected legacy subsystem without subvendor, but got %+v", s)
+	}
+	if db.Classes == nil || db.Products["101e1960"].VendorID != "101e" {
+		t.Fatalf("Expected missing classes and product vendor IDs to be filled in")
+	}
+
+	invalid := map[string]string{
+		"newer schema":     `{"schema_version": 2, "vendors": {}, "classes": {}}`,
+		"not JSON":         `{"vendors": `,
+		"mismatched key":   `{"vendors": {"8086": {"id": "8087", "name": "Intel"}}}`,
+		"uppercase ID":     `{"vendors": {"10DE": {"id": "10DE", "name": "NVIDIA"}}}`,
+		"short product ID": `{"vendors": {"8086": {"id": "8086", "products": [{"id": "10f"}]}}}`,
+		"null subsystem":   `{"vendors": {"8086": {"id": "8086", "products": [{"id": "10f8", "subsystems": [null]}]}}}`,
+		"long class ID":    `{"classes": {"0c0": {"id": "0c0", "name": "Serial bus controller"}}}`,
+		"invalid subclass": `{"classes": {"0c": {"id": "0c", "subclasses": [{"id": "zz"}]}}}`,
+		"missing prog-if":  `{"classes": {"0c": {"id": "0c", "subclasses": [{"id": "03", "programming_interfaces": [{}]}]}}}`,
+	}
+	for name, doc := range invalid {
+		if _, err := FromJSON(strings.NewReader(doc)); !errors.Is(err, types.ErrInvalidDB) {
+			t.Fatalf("Expected ErrInvalidDB for %s, but got %v", name, err)
+		}
+	}
+}
+
+func TestJSONSchemaFile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "schema", "pcidb-v1.schema.json"))
+	if err != nil {
+		t.Fatalf("Expected no error reading schema, but got %v", err)
+	}
+	var schema struct {
+		Properties struct {
+			SchemaVersion struct {
+				Const int `json:"const"`
+			} `json:"schema_version"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Expected schema to be valid JSON, but got %v", err)
+	}
+	if schema.Properties.SchemaVersion.Const != JSONSchemaVersion {
+		t.Fatalf("Expected schema for version %d but got %d",
+			JSONSchemaVersion, schema.Properties.SchemaVersion.Const)
+	}
+}
@@ -0,0 +1,316 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// Lazy is a DB that parses each vendor's products and subsystems only when
+// the vendor is first looked up.
+//
+// Loading a Lazy reads the pci-ids DB file into memory and scans it once to
+// build an index of where each vendor block starts and ends. Classes and
+// overlay pci-ids DB files are small and are parsed up front. Lookups return
+// exactly what the eagerly-loaded DB contains: looking up a vendor also parses
+// the vendors that its subsystems refer to as their subvendors, and theirs in
+// turn.
+type Lazy struct {
+	text    string
+	blocks  map[string]string // vendor ID -> vendor block text
+	classes map[string]*types.Class
+	filter  *filter
+	// overlays are the parsed overlay pci-ids DB files, whose vendors are
+	// merged into each vendor when its block is parsed, and layers are the
+	// paths of the pci-ids DB file and the overlays
+	overlays []*types.DB
+	layers   []string
+
+	lock    sync.Mutex
+	vendors map[string]*types.Vendor // vendors referenced so far
+	parsed  map[string]bool          // vendors whose blocks have been parsed
+}
+
+// LoadLazy discovers and opens a pci-ids DB file as described by the supplied
+// options and returns a Lazy for it, with any overlay pci-ids DB files merged
+// on top of it as Load does.
+func LoadLazy(opts *types.WithOption) (*Lazy, error) {
+	foundPath, err := resolvePath(opts)
+	if err != nil {
+		return nil, err
+	}
+	overlays := overlayPaths(opts)
+	f, err := openPath(opts, foundPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l := newLazy(readAll(f), newFilter(opts))
+	if foundPath == "" {
+		foundPath = fetchURL(opts)
+	}
+	if err := l.applyOverlays(foundPath, overlays); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// newLazy indexes the vendor and class blocks in the supplied pci-ids DB file
+// contents that the supplied filter allows
+func newLazy(text string, flt *filter) *Lazy {
+	l := &Lazy{
+		text:    text,
+		filter:  flt,
+		blocks:  map[string]string{},
+		vendors: map[string]*types.Vendor{},
+		parsed:  map[string]bool{},
+	}
+	// Class blocks are normally contiguous at the end of the file. Parsing
+	// the span from the first to the end of the last class block yields every
+	// class even if vendor blocks were interleaved, since only the classes of
+	// the result are kept.
+	classStart, classEnd := -1, -1
+	blockStart, blockID, isClass := -1, "", false
+	finishBlock := func(end int) {
+		switch {
+		case blockStart < 0:
+		case isClass:
+			if classStart < 0 {
+				classStart = blockStart
+			}
+			classEnd = end
+		default:
+			l.blocks[blockID] = text[blockStart:end]
+		}
+	}
+	// When comments are kept, a block starts at the comment lines directly
+	// above its header line, which belong to the block's vendor or class
+	inClassBlock, commentStart := false, -1
+	for off := 0; off < len(text); {
+		line, rest := nextLine(text[off:])
+		var kind entryKind
+		kind, inClassBlock = classify(line, inClassBlock)
+		kind, _ = flt.apply(kind, line, false)
+		start := off
+		if commentStart >= 0 && flt.keepComments() {
+			start = commentStart
+		}
+		switch kind {
+		case kindVendor, kindClass:
+			finishBlock(start)
+			blockStart, blockID, isClass = start, line[0:4], kind == kindClass
+		case kindSkipped:
+			finishBlock(start)
+			blockStart = -1
+		}
+		if !isComment(line) {
+			commentStart = -1
+		} else if commentStart < 0 {
+			commentStart = off
+		}
+		off = len(text) - len(rest)
+	}
+	finishBlock(len(text))
+	l.classes = map[string]*types.Class{}
+	if classStart >= 0 {
+		l.classes = parseText(text[classStart:classEnd], flt).Classes
+	}
+	return l
+}
+
+// applyOverlays parses the overlay pci-ids DB files at the supplied paths and
+// merges their classes, in order, on top of the Lazy's classes, which were
+// loaded from the supplied base path. Their vendors are merged when each
+// vendor is parsed.
+func (l *Lazy) applyOverlays(basePath string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	for x, path := range paths {
+		f, err := openDBFile(path)
+		if err != nil {
+			return err
+		}
+		overlay := fromReader(f, l.filter)
+		for _, oc := range overlay.Classes {
+			mergeClass(l.classes, oc, x+1)
+		}
+		l.overlays = append(l.overlays, overlay)
+	}
+	l.layers = append([]string{basePath}, paths...)
+	return nil
+}
+
+// Vendor returns the vendor with the supplied hex-encoded ID, including its
+// products and their subsystems, or nil if there is no such vendor.
+func (l *Lazy) Vendor(id string) *types.Vendor {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.vendor(id)
+}
+
+// vendor returns the vendor with the supplied ID after parsing it and every
+// vendor its subsystems refer to as their subvendor, transitively, so that
+// subvendors are as complete as they are in the eagerly-loaded DB
+func (l *Lazy) vendor(id string) *types.Vendor {
+	for pending := []string{id}; len(pending) > 0; {
+		next := pending[len(pending)-1]
+		pending = append(pending[:len(pending)-1], l.parse(next)...)
+	}
+	return l.vendors[id]
+}
+
+// parse parses the block of the vendor with the supplied ID, if it hasn't
+// been parsed yet, merges the overlays into it and returns the IDs of the
+// subvendors it refers to that haven't been parsed yet
+func (l *Lazy) parse(id string) []string {
+	if l.parsed[id] {
+		return nil
+	}
+	v := l.shell(id)
+	if v == nil {
+		return nil
+	}
+	l.parsed[id] = true
+	vendors := map[string]*types.Vendor{}
+	if block, exists := l.blocks[id]; exists {
+		v.Products = parseText(block, l.filter).Vendors[id].Products
+		vendors[id] = v
+	}
+	for x, overlay := range l.overlays {
+		if ov := overlay.Vendors[id]; ov != nil {
+			mergeVendor(vendors, ov, x+1)
+		}
+	}
+	var pending []string
+	for _, p := range v.Products {
+		p.Vendor = v
+		for _, s := range p.Subsystems {
+			s.Subvendor = l.shell(s.SubvendorID)
+			if s.Subvendor != nil && !l.parsed[s.SubvendorID] {
+				pending = append(pending, s.SubvendorID)
+			}
+		}
+	}
+	return pending
+}
+
+// shell returns the vendor with the supplied ID, which only has its products
+// once it has been parsed, or nil if there is no such vendor. Subsystems refer
+// to their subvendors' shells while the subvendors are waiting to be parsed,
+// and a shell is completed in place when it is parsed. The shell of a vendor
+// that is only in overlays is the vendor from the first overlay that has it,
+// which the later overlays are merged into.
+func (l *Lazy) shell(id string) *types.Vendor {
+	if v, exists := l.vendors[id]; exists {
+		return v
+	}
+	block, exists := l.blocks[id]
+	if !exists {
+		for _, overlay := range l.overlays {
+			if v := overlay.Vendors[id]; v != nil {
+				l.vendors[id] = v
+				return v
+			}
+		}
+		return nil
+	}
+	line, rest := nextLine(block)
+	var comments []string
+	for isComment(line) {
+		comments = append(comments, commentText(line))
+		line, rest = nextLine(rest)
+	}
+	v := &types.Vendor{
+		ID:        line[0:4],
+		NumericID: types.VendorID(hexValue(line[0:4])),
+		Name:      nameFrom(line, 6),
+		Comments:  comments,
+	}
+	l.vendors[id] = v
+	return v
+}
+
+// Product returns the product with the supplied hex-encoded vendor and
+// product IDs, including its subsystems, or nil if there is no such product.
+func (l *Lazy) Product(vendorID string, productID string) *types.Product {
+	v := l.Vendor(vendorID)
+	if v == nil {
+		return nil
+	}
+	for _, p := range v.Products {
+		if p.ID == productID {
+			return p
+		}
+	}
+	return nil
+}
+
+// VendorByID returns the vendor with the supplied vendor ID, including its
+// products and their subsystems, or nil if there is no such vendor.
+func (l *Lazy) VendorByID(id types.VendorID) *types.Vendor {
+	return l.Vendor(id.String())
+}
+
+// ProductByID returns the product with the supplied vendor and device IDs,
+// including its subsystems, or nil if there is no such product.
+func (l *Lazy) ProductByID(
+	vendorID types.VendorID,
+	deviceID types.DeviceID,
+) *types.Product {
+	return l.Product(vendorID.String(), deviceID.String())
+}
+
+// ClassByID returns the class with the supplied class ID, including its
+// subclasses and programming interfaces, or nil if there is no such class.
+func (l *Lazy) ClassByID(id types.ClassID) *types.Class {
+	return l.Class(id.String())
+}
+
+// Class returns the class with the supplied hex-encoded ID, including its
+// subclasses and programming interfaces, or nil if there is no such class.
+func (l *Lazy) Class(id string) *types.Class {
+	return l.classes[id]
+}
+
+// VendorIDs returns the sorted IDs of every vendor in the DB without parsing
+// any vendor blocks.
+func (l *Lazy) VendorIDs() []string {
+	ids := make([]string, 0, len(l.blocks))
+	for id := range l.blocks {
+		ids = append(ids, id)
+	}
+	for _, overlay := range l.overlays {
+		for id := range overlay.Vendors {
+			if _, exists := l.blocks[id]; !exists {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return slices.Compact(ids)
+}
+
+// DB parses every remaining vendor block and returns the entire DB.
+func (l *Lazy) DB() *types.DB {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	db := &types.DB{
+		Classes: l.classes,
+		Vendors: make(map[string]*types.Vendor, len(l.blocks)),
+		Layers:  l.layers,
+	}
+	for _, id := range l.VendorIDs() {
+		db.Vendors[id] = l.vendor(id)
+	}
+	indexDB(db)
+	return db
+}
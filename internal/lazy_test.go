@@ -0,0 +1,181 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestLazy(t *testing.T) {
+	fixture := basicFixture
+	f, err := openDBFile(fixture)
+	if err != nil {
+		t.Fatalf("Expected no error opening fixture, but got %v", err)
+	}
+	eager := FromReader(f)
+
+	lazy, err := LoadLazy(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	if len(lazy.parsed) != 0 {
+		t.Fatalf("Expected no vendors to be parsed before lookup")
+	}
+	if len(lazy.VendorIDs()) != len(eager.Vendors) {
+		t.Fatalf("Expected %d vendor IDs, but got %d", len(eager.Vendors), len(lazy.VendorIDs()))
+	}
+
+	megaRaid := lazy.Product("101e", "1960")
+	if megaRaid == nil || len(megaRaid.Subsystems) != 3 {
+		t.Fatalf("Expected MegaRAID product with 3 subsystems, but got %+v", megaRaid)
+	}
+	// The MegaRAID's subsystems refer to Dell and HP as subvendors
+	if len(lazy.parsed) != 3 || !lazy.parsed["1028"] || !lazy.parsed["103c"] {
+		t.Fatalf("Expected only the looked up vendor and its subvendors to be parsed, but got %v",
+			lazy.parsed)
+	}
+	if dell := megaRaid.Subsystems[1].Subvendor; dell != lazy.Vendor("1028") || len(dell.Products) != 1 {
+		t.Fatalf("Expected the subvendor of a subsystem to have its products, but got %+v", dell)
+	}
+	if lazy.Vendor("101e") != lazy.Vendor("101e") {
+		t.Fatalf("Expected repeated lookups to return the same vendor")
+	}
+	if lazy.Vendor("dead") != nil || lazy.Product("8086", "ffff") != nil {
+		t.Fatalf("Expected unknown IDs to return nil")
+	}
+	if cls := lazy.Class("0c"); cls == nil || cls.Name != "Serial bus controller" {
+		t.Fatalf("Expected serial bus controller class, but got %+v", cls)
+	}
+
+	want, _ := json.Marshal(eager)
+	got, _ := json.Marshal(lazy.DB())
+	if string(want) != string(got) {
+		t.Fatalf("Expected lazy DB to match eagerly-loaded DB\nwant: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestLazyMatchesEager(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	root := t.TempDir()
+	writeFile := func(path string, lines ...string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Expected no error creating directory, but got %v", err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatalf("Expected no error writing file, but got %v", err)
+		}
+	}
+	writeFile(filepath.Join("usr", "share", "hwdata", "pci.ids"), string(contents))
+	writeFile(filepath.Join(types.DefaultOverlayDir, "10-site.ids"),
+		"8086",
+		"\t0b60  Internal FPGA Accelerator",
+		"\t\tabcd 0001  FPGA Accelerator Rev A",
+		"1590  HPE",
+		"abcd  Example Silicon Inc.",
+		"\t0001  Pre-release NIC",
+		"\t\t8086 0002  Pre-release NIC for Intel",
+		"C 02",
+		"\t80  Network controller",
+		"\t\t01  Prototype",
+	)
+	writeFile(filepath.Join(types.DefaultOverlayDir, "20-lab.ids"),
+		"abcd  Example Silicon",
+		"\t0002  Lab NIC",
+	)
+
+	filters := [][]*types.WithOption{{}, {types.WithVendors("101e", "8086", "abcd")}}
+	for _, filter := range filters {
+		opts := MergeOptions(append([]*types.WithOption{
+			types.WithChroot(root), types.WithCachePath(""),
+		}, filter...)...)
+		eager, err := Load(MergeOptions(opts, types.WithDisableMemoization()))
+		if err != nil {
+			t.Fatalf("Expected no error loading DB, but got %v", err)
+		}
+		shared, err := LoadLazy(opts)
+		if err != nil {
+			t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+		}
+		wantIDs := make([]string, 0, len(eager.Vendors))
+		for id := range eager.Vendors {
+			wantIDs = append(wantIDs, id)
+		}
+		if ids := shared.VendorIDs(); len(ids) != len(wantIDs) {
+			t.Fatalf("Expected vendor IDs %q but got %q", wantIDs, ids)
+		}
+		for id, want := range eager.Vendors {
+			// A fresh Lazy for each vendor, so that the vendor is the first
+			// one looked up
+			lazy, err := LoadLazy(opts)
+			if err != nil {
+				t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+			}
+			sameVendor(t, want, lazy.Vendor(id))
+			sameVendor(t, want, shared.Vendor(id))
+			for _, p := range want.Products {
+				if got := lazy.Product(id, p.ID); got == nil || got.Name != p.Name {
+					t.Fatalf("Expected product %s%s %q but got %+v", id, p.ID, p.Name, got)
+				}
+			}
+		}
+		for id, want := range eager.Classes {
+			wantJSON, _ := json.Marshal(want)
+			gotJSON, _ := json.Marshal(shared.Class(id))
+			if string(wantJSON) != string(gotJSON) {
+				t.Fatalf("Expected class %s\nwant: %s\ngot:  %s", id, wantJSON, gotJSON)
+			}
+		}
+		db := shared.DB()
+		if !reflect.DeepEqual(db.Layers, eager.Layers) || len(db.Layers) != 3 {
+			t.Fatalf("Expected layers %q but got %q", eager.Layers, db.Layers)
+		}
+		want, _ := json.Marshal(eager)
+		got, _ := json.Marshal(db)
+		if string(want) != string(got) {
+			t.Fatalf("Expected lazy DB to match eagerly-loaded DB\nwant: %s\ngot:  %s", want, got)
+		}
+	}
+}
+
+// sameVendor fails the test unless the supplied lazily-loaded vendor matches
+// the supplied eagerly-loaded vendor, down to the products of the subvendor
+// of each subsystem
+func sameVendor(t *testing.T, want *types.Vendor, got *types.Vendor) {
+	t.Helper()
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if string(wantJSON) != string(gotJSON) {
+		t.Fatalf("Expected vendor %s\nwant: %s\ngot:  %s", want.ID, wantJSON, gotJSON)
+	}
+	for x, p := range got.Products {
+		if p.Vendor != got {
+			t.Fatalf("Expected product %s%s to link to its vendor", got.ID, p.ID)
+		}
+		for y, s := range p.Subsystems {
+			if s.Product != p {
+				t.Fatalf("Expected subsystem %s to link to its product", s.SubdeviceID)
+			}
+			wantSub, _ := json.Marshal(want.Products[x].Subsystems[y].Subvendor)
+			gotSub, _ := json.Marshal(s.Subvendor)
+			if string(wantSub) != string(gotSub) {
+				t.Fatalf("Expected subvendor %s of product %s%s\nwant: %s\ngot:  %s",
+					s.SubvendorID, got.ID, p.ID, wantSub, gotSub)
+			}
+		}
+	}
+}
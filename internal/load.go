@@ -0,0 +1,227 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// memoKey identifies a pci-ids DB file and the options that affect how it is
+// loaded
+type memoKey struct {
+	path     string
+	checksum string
+	filter   string
+	overlays string
+}
+
+// memoEntry is a DB parsed from a pci-ids DB file with a particular size and
+// modification time. Its fields other than loading are guarded by memoLock.
+type memoEntry struct {
+	// loading is held while the entry's DB is loaded, so that concurrent
+	// callers wait for a single parse of the same file instead of each
+	// parsing it themselves, without holding up loads of other files
+	loading  sync.Mutex
+	size     int64
+	modTime  time.Time
+	overlays string
+	db       *types.DB
+}
+
+var (
+	memoLock sync.Mutex
+	memo     = map[memoKey]*memoEntry{}
+)
+
+// memoEntryFor returns the memo entry for the supplied key, adding an empty
+// one if there is none
+func memoEntryFor(key memoKey) *memoEntry {
+	memoLock.Lock()
+	defer memoLock.Unlock()
+	entry, exists := memo[key]
+	if !exists {
+		entry = &memoEntry{}
+		memo[key] = entry
+	}
+	return entry
+}
+
+// memoized returns the DB of the supplied memo entry if it was loaded from
+// the file described by the supplied file info with the overlays described
+// by the supplied stamp, or nil otherwise
+func memoized(entry *memoEntry, fi os.FileInfo, stamp string) *types.DB {
+	memoLock.Lock()
+	defer memoLock.Unlock()
+	if entry.db == nil || entry.size != fi.Size() ||
+		!entry.modTime.Equal(fi.ModTime()) || entry.overlays != stamp {
+		return nil
+	}
+	return entry.db
+}
+
+// memoStore stores the supplied DB, loaded from the file described by the
+// supplied file info, in the memo entry for the supplied key, and evicts the
+// entries for other versions of the same file, which can never be returned
+// again. A nil DB, from a failed load, removes the entry unless it holds a DB
+// already.
+func memoStore(
+	key memoKey,
+	entry *memoEntry,
+	fi os.FileInfo,
+	stamp string,
+	db *types.DB,
+) {
+	memoLock.Lock()
+	defer memoLock.Unlock()
+	if db == nil {
+		if entry.db == nil && memo[key] == entry {
+			delete(memo, key)
+		}
+		return
+	}
+	entry.size = fi.Size()
+	entry.modTime = fi.ModTime()
+	entry.overlays = stamp
+	entry.db = db
+	for k, e := range memo {
+		if k.path == key.path && e.db != nil &&
+			(e.size != fi.Size() || !e.modTime.Equal(fi.ModTime())) {
+			delete(memo, k)
+		}
+	}
+}
+
+// Load discovers, opens and parses a pci-ids DB file as described by the
+// supplied options.
+//
+// Parsed DBs are memoized, keyed by the resolved path of the pci-ids DB file,
+// so that repeated calls with equivalent options return the same *types.DB
+// for as long as the file's size and modification time are unchanged.
+// Memoization is skipped for DBs fetched into memory from the network and
+// when disabled with types.WithDisableMemoization. Concurrent loads of the
+// same file wait for a single parse, and memoized DBs for earlier versions of
+// a file are dropped once it is loaded again.
+//
+// When the pci-ids DB file is the pcidb cache file, the compiled form of the
+// DB is stored next to it and used in preference to parsing the file for as
+// long as the file is unchanged. The products of each vendor in a DB loaded
+// from the compiled form are ordered by ID rather than in file order.
+//
+// Overlay pci-ids DB files, from the overlay directory under the chroot and
+// added with types.WithOverlay, are merged on top of the DB in order. A
+// memoized DB is only returned while the overlays are unchanged as well.
+func Load(opts *types.WithOption) (*types.DB, error) {
+	foundPath, err := resolvePath(opts)
+	if err != nil {
+		return nil, err
+	}
+	overlays := overlayPaths(opts)
+	if foundPath == "" {
+		f, err := openPath(opts, foundPath)
+		if err != nil {
+			return nil, err
+		}
+		db := fromReader(f, newFilter(opts))
+		if err := applyOverlays(db, fetchURL(opts), overlays, newFilter(opts)); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+
+	fi, err := os.Stat(foundPath)
+	if err != nil {
+		return nil, err
+	}
+	if !memoize(opts) {
+		return loadLayers(opts, foundPath, fi, overlays)
+	}
+	stamp, err := overlayStamp(overlays)
+	if err != nil {
+		return nil, err
+	}
+	key := memoKey{
+		path:     foundPath,
+		filter:   newFilter(opts).key(),
+		overlays: strings.Join(overlays, "\x00"),
+	}
+	if opts.ExpectedChecksum != nil {
+		key.checksum = *opts.ExpectedChecksum
+	}
+
+	entry := memoEntryFor(key)
+	entry.loading.Lock()
+	defer entry.loading.Unlock()
+	if db := memoized(entry, fi, stamp); db != nil {
+		return db, nil
+	}
+	db, err := loadLayers(opts, foundPath, fi, overlays)
+	memoStore(key, entry, fi, stamp, db)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// loadLayers returns the DB for the pci-ids DB file at the supplied path with
+// the overlay pci-ids DB files at the supplied paths merged on top of it
+func loadLayers(
+	opts *types.WithOption,
+	foundPath string,
+	fi os.FileInfo,
+	overlays []string,
+) (*types.DB, error) {
+	db, err := loadPath(opts, foundPath, fi)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverlays(db, foundPath, overlays, newFilter(opts)); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// loadPath returns the DB for the pci-ids DB file at the supplied path,
+// using the compiled cache if the path is the pcidb cache file. The compiled
+// cache always holds the entire DB, so it is not used when the options
+// filter what is loaded.
+func loadPath(
+	opts *types.WithOption,
+	foundPath string,
+	fi os.FileInfo,
+) (*types.DB, error) {
+	flt := newFilter(opts)
+	cachePath := NewCache(opts).Path()
+	isCached := flt == nil && cachePath != "" &&
+		(foundPath == cachePath || foundPath == compressedCachePath(cachePath))
+	checksum := opts.ExpectedChecksum != nil && *opts.ExpectedChecksum != ""
+	if isCached && !checksum {
+		if db := loadCompiledCache(cachePath, foundPath, fi); db != nil {
+			return db, nil
+		}
+	}
+	f, err := openPath(opts, foundPath)
+	if err != nil {
+		return nil, err
+	}
+	db := fromReader(f, flt)
+	if isCached {
+		// Store the compiled form next to the cached pci-ids DB file so that
+		// subsequent loads don't need to parse it. Failing to do so is
+		// harmless.
+		writeCompiledCache(cachePath, db, foundPath, fi)
+	}
+	return db, nil
+}
+
+func memoize(opts *types.WithOption) bool {
+	return opts.DisableMemoization == nil || !*opts.DisableMemoization
+}
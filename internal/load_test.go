@@ -0,0 +1,164 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestLoadMemoization(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+
+	first, err := Load(MergeOptions(types.WithPath(path)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	second, err := Load(MergeOptions(types.WithPath(path)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if first != second {
+		t.Fatalf("Expected equivalent loads to share a single DB")
+	}
+
+	private, err := Load(MergeOptions(
+		types.WithPath(path), types.WithDisableMemoization(),
+	))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if private == first {
+		t.Fatalf("Expected WithDisableMemoization to return a private DB")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Expected no error changing mtime, but got %v", err)
+	}
+	third, err := Load(MergeOptions(types.WithPath(path)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if third == first {
+		t.Fatalf("Expected a modified file to be parsed again")
+	}
+}
+
+func TestLoadMemoEviction(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	entries := func() int {
+		memoLock.Lock()
+		defer memoLock.Unlock()
+		n := 0
+		for key := range memo {
+			if key.path == path {
+				n++
+			}
+		}
+		return n
+	}
+
+	if _, err := Load(MergeOptions(types.WithPath(path))); err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	filtered := MergeOptions(types.WithPath(path), types.WithVendors("8086"))
+	if _, err := Load(filtered); err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if n := entries(); n != 2 {
+		t.Fatalf("Expected 2 memoized DBs but got %d", n)
+	}
+
+	// Reloading the modified file drops the DB memoized for the earlier
+	// version of it with other options
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Expected no error changing mtime, but got %v", err)
+	}
+	if _, err := Load(MergeOptions(types.WithPath(path))); err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if n := entries(); n != 1 {
+		t.Fatalf("Expected 1 memoized DB but got %d", n)
+	}
+}
+
+func TestLoadConcurrentFiles(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	dir := t.TempDir()
+	slow := filepath.Join(dir, "slow.ids")
+	fast := filepath.Join(dir, "fast.ids")
+	for _, path := range []string{slow, fast} {
+		if err := os.WriteFile(path, contents, 0o644); err != nil {
+			t.Fatalf("Expected no error writing file, but got %v", err)
+		}
+	}
+
+	// Stand in for a slow load of one file, which mustn't hold up loading
+	// another
+	entry := memoEntryFor(memoKey{path: slow})
+	entry.loading.Lock()
+	defer func() {
+		entry.loading.Unlock()
+		memoLock.Lock()
+		delete(memo, memoKey{path: slow})
+		memoLock.Unlock()
+	}()
+	done := make(chan error)
+	go func() {
+		_, err := Load(MergeOptions(types.WithPath(fast)))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected no error loading DB, but got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected loading a file not to wait for loading another")
+	}
+
+	// Concurrent loads of the same file share a single DB
+	var wg sync.WaitGroup
+	dbs := make([]*types.DB, 4)
+	for x := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dbs[x], _ = Load(MergeOptions(types.WithPath(fast)))
+		}()
+	}
+	wg.Wait()
+	for _, db := range dbs {
+		if db == nil || db != dbs[0] {
+			t.Fatalf("Expected concurrent loads to share a single DB")
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+//go:build !unix
+
+package internal
+
+import "os"
+
+// mapFile reads the file at the supplied path into memory on platforms
+// without mmap support, returning the data and a no-op release function
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
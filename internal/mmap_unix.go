@@ -0,0 +1,38 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile memory-maps the file at the supplied path read-only, returning the
+// mapped data and a function that unmaps it
+func mapFile(path string) ([]byte, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if fi.Size() == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(
+		int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
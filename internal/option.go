@@ -24,6 +24,10 @@ func MergeOptions(opts ...*types.WithOption) *types.WithOption {
 	if val, exists := os.LookupEnv(types.EnvVarPath); exists {
 		path = val
 	}
+	cachePath := types.DefaultCachePath
+	if val, exists := os.LookupEnv(types.EnvVarCachePath); exists {
+		cachePath = val
+	}
 	cacheOnly := types.DefaultCacheOnly
 	if val, exists := os.LookupEnv(types.EnvVarCacheOnly); exists {
 		if parsed, err := strconv.ParseBool(val); err != nil {
@@ -37,6 +41,38 @@ func MergeOptions(opts ...*types.WithOption) *types.WithOption {
 			cacheOnly = parsed
 		}
 	}
+	cacheCompress := types.DefaultCacheCompress
+	if val, exists := os.LookupEnv(types.EnvVarCacheCompress); exists {
+		if parsed, err := strconv.ParseBool(val); err != nil {
+			fmt.Fprintf(
+				os.Stderr,
+				"Failed parsing a bool from %s environ value of %s",
+				types.EnvVarCacheCompress, val,
+			)
+		} else if parsed {
+			cacheCompress = parsed
+		}
+	}
+	cacheRetention := types.DefaultCacheRetention
+	if val, exists := os.LookupEnv(types.EnvVarCacheRetention); exists {
+		if parsed, err := strconv.Atoi(val); err != nil {
+			fmt.Fprintf(
+				os.Stderr,
+				"Failed parsing an int from %s environ value of %s",
+				types.EnvVarCacheRetention, val,
+			)
+		} else {
+			cacheRetention = parsed
+		}
+	}
+	version := ""
+	if val, exists := os.LookupEnv(types.EnvVarVersion); exists {
+		version = val
+	}
+	expectedChecksum := ""
+	if val, exists := os.LookupEnv(types.EnvVarExpectedChecksum); exists {
+		expectedChecksum = val
+	}
 	enableNetworkFetch := types.DefaultEnableNetworkFetch
 	if val, exists := os.LookupEnv(types.EnvVarEnableNetworkFetch); exists {
 		if parsed, err := strconv.ParseBool(val); err != nil {
@@ -49,34 +85,136 @@ func MergeOptions(opts ...*types.WithOption) *types.WithOption {
 			enableNetworkFetch = parsed
 		}
 	}
+	fetchURL := types.DefaultFetchURL
+	if val, exists := os.LookupEnv(types.EnvVarFetchURL); exists {
+		fetchURL = val
+	}
+
+	disableMemoization := types.DefaultDisableMemoization
+	skipSubsystems := false
+	comments := false
+	pollInterval := types.DefaultPollInterval
+	alerter := types.DefaultAlerter
+	if val, exists := os.LookupEnv(types.EnvVarDisableWarnings); exists {
+		if parsed, err := strconv.ParseBool(val); err != nil {
+			fmt.Fprintf(
+				os.Stderr,
+				"Failed parsing a bool from %s environ value of %s",
+				types.EnvVarDisableWarnings, val,
+			)
+		} else if parsed {
+			alerter = types.NullAlerter
+		}
+	}
 
 	merged := &types.WithOption{}
 	for _, opt := range opts {
 		if opt.Chroot != nil {
 			merged.Chroot = opt.Chroot
 		}
+		if opt.CachePath != nil {
+			merged.CachePath = opt.CachePath
+		}
 		if opt.CacheOnly != nil {
 			merged.CacheOnly = opt.CacheOnly
 		}
+		if opt.CacheCompress != nil {
+			merged.CacheCompress = opt.CacheCompress
+		}
+		if opt.CacheRetention != nil {
+			merged.CacheRetention = opt.CacheRetention
+		}
+		if opt.Version != nil {
+			merged.Version = opt.Version
+		}
+		if opt.ExpectedChecksum != nil {
+			merged.ExpectedChecksum = opt.ExpectedChecksum
+		}
 		if opt.EnableNetworkFetch != nil {
 			merged.EnableNetworkFetch = opt.EnableNetworkFetch
 		}
+		if opt.FetchURL != nil {
+			merged.FetchURL = opt.FetchURL
+		}
 		if opt.Path != nil {
 			merged.Path = opt.Path
 		}
+		if opt.DisableMemoization != nil {
+			merged.DisableMemoization = opt.DisableMemoization
+		}
+		if opt.PollInterval != nil {
+			merged.PollInterval = opt.PollInterval
+		}
+		if opt.Alerter != nil {
+			merged.Alerter = opt.Alerter
+		}
+		if opt.Vendors != nil {
+			merged.Vendors = opt.Vendors
+		}
+		if opt.Classes != nil {
+			merged.Classes = opt.Classes
+		}
+		if opt.SkipSubsystems != nil {
+			merged.SkipSubsystems = opt.SkipSubsystems
+		}
+		if opt.Comments != nil {
+			merged.Comments = opt.Comments
+		}
+		merged.Overlays = append(merged.Overlays, opt.Overlays...)
 	}
 	// Set the default value if missing from merged
 	if merged.Chroot == nil {
 		merged.Chroot = &chroot
 	}
+	if merged.CachePath == nil {
+		merged.CachePath = &cachePath
+	}
 	if merged.CacheOnly == nil {
 		merged.CacheOnly = &cacheOnly
 	}
+	if merged.CacheCompress == nil {
+		merged.CacheCompress = &cacheCompress
+	}
+	if merged.CacheRetention == nil {
+		merged.CacheRetention = &cacheRetention
+	}
+	if merged.Version == nil {
+		merged.Version = &version
+	}
+	if merged.ExpectedChecksum == nil {
+		merged.ExpectedChecksum = &expectedChecksum
+	}
 	if merged.EnableNetworkFetch == nil {
 		merged.EnableNetworkFetch = &enableNetworkFetch
 	}
+	if merged.FetchURL == nil {
+		merged.FetchURL = &fetchURL
+	}
 	if merged.Path == nil {
 		merged.Path = &path
 	}
+	if merged.DisableMemoization == nil {
+		merged.DisableMemoization = &disableMemoization
+	}
+	if merged.PollInterval == nil {
+		merged.PollInterval = &pollInterval
+	}
+	if merged.Alerter == nil {
+		merged.Alerter = alerter
+	}
+	if merged.SkipSubsystems == nil {
+		merged.SkipSubsystems = &skipSubsystems
+	}
+	if merged.Comments == nil {
+		merged.Comments = &comments
+	}
 	return merged
 }
+
+// alerter returns the Alerter to send warnings to for the supplied options
+func alerter(opts *types.WithOption) types.Alerter {
+	if opts.Alerter != nil {
+		return opts.Alerter
+	}
+	return types.DefaultAlerter
+}
@@ -0,0 +1,267 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// overlayPaths returns the paths of the overlay pci-ids DB files to merge on
+// top of the DB described by the supplied options, in order of increasing
+// precedence: the *.ids files in the overlay directory under the chroot, in
+// lexical order, followed by the overlays added with types.WithOverlay, in
+// the order they were added. As with the well-known locations of the pci-ids
+// DB file, the overlay directory is not read when an explicit path is set or
+// only the pcidb cache is searched. An overlay directory that can't be read is
+// skipped with a warning.
+func overlayPaths(opts *types.WithOption) []string {
+	paths := []string{}
+	explicit := opts.Path != nil && *opts.Path != ""
+	cacheOnly := opts.CacheOnly != nil && *opts.CacheOnly
+	if !explicit && !cacheOnly {
+		rootPath := types.DefaultChroot
+		if opts.Chroot != nil && *opts.Chroot != "" {
+			rootPath = *opts.Chroot
+		}
+		dir := filepath.Join(rootPath, types.DefaultOverlayDir)
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			// As with an unwritable cache, a broken overlay directory
+			// shouldn't stop the DB from loading
+			alerter(opts).Printf(
+				"pcidb: skipping unreadable overlay directory %s: %v",
+				dir, err,
+			)
+			entries = nil
+		}
+		// os.ReadDir returns the entries sorted by filename
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".ids") {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return append(paths, opts.Overlays...)
+}
+
+// overlayStamp returns a string that changes whenever the set of supplied
+// overlay pci-ids DB files or the size or modification time of any of them
+// changes
+func overlayStamp(paths []string) (string, error) {
+	var b strings.Builder
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\x00", path, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// applyOverlays merges the overlay pci-ids DB files at the supplied paths, in
+// order, on top of the supplied DB, which was loaded from the supplied base
+// path, only keeping the entries the supplied filter allows. The DB's Layers
+// are set to the base path followed by the overlay paths and its flat maps
+// are rebuilt. The DB is left unchanged if there are no overlays.
+func applyOverlays(
+	db *types.DB,
+	basePath string,
+	paths []string,
+	flt *filter,
+) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	for x, path := range paths {
+		f, err := openDBFile(path)
+		if err != nil {
+			return err
+		}
+		mergeLayer(db, fromReader(f, flt), x+1)
+	}
+	db.Layers = append([]string{basePath}, paths...)
+	indexDB(db)
+	for _, s := range db.Subsystems {
+		s.Subvendor = db.Vendors[s.SubvendorID]
+	}
+	return nil
+}
+
+// mergeLayer merges the entries of the supplied overlay DB into the supplied
+// DB, recording the supplied layer index on every entry that the overlay adds
+// or renames. An entry that the overlay lists with the same name, or without
+// a name, only refers to the entry in the DB, so that an overlay can add
+// products to a vendor, or subclasses to a class, without restating its name.
+// Products, subclasses and programming interfaces are kept sorted by ID.
+func mergeLayer(db *types.DB, overlay *types.DB, layer int) {
+	for _, ov := range overlay.Vendors {
+		mergeVendor(db.Vendors, ov, layer)
+	}
+	for _, oc := range overlay.Classes {
+		mergeClass(db.Classes, oc, layer)
+	}
+}
+
+// mergeVendor merges the supplied overlay vendor into the vendor with the
+// same ID in the supplied vendors, or adds it to them, as mergeLayer does
+func mergeVendor(vendors map[string]*types.Vendor, ov *types.Vendor, layer int) {
+	v := vendors[ov.ID]
+	if v == nil {
+		setVendorLayer(ov, layer)
+		vendors[ov.ID] = ov
+		return
+	}
+	rename(&v.Name, &v.Comments, &v.Layer, ov.Name, ov.Comments, layer)
+	added := false
+	for _, op := range ov.Products {
+		p := findByID(v.Products, productID, op.ID)
+		if p == nil {
+			setProductLayer(op, layer)
+			op.Vendor = v
+			v.Products = append(v.Products, op)
+			added = true
+			continue
+		}
+		rename(&p.Name, &p.Comments, &p.Layer, op.Name, op.Comments, layer)
+		for _, osub := range op.Subsystems {
+			s := findSubsystem(p.Subsystems, osub.SubvendorID, osub.SubdeviceID)
+			if s == nil {
+				osub.Layer = layer
+				osub.Product = p
+				p.Subsystems = append(p.Subsystems, osub)
+				continue
+			}
+			rename(&s.Name, &s.Comments, &s.Layer, osub.Name, osub.Comments, layer)
+		}
+	}
+	if added {
+		v.Products = sortedByID(v.Products, productID)
+	}
+}
+
+// mergeClass merges the supplied overlay class into the class with the same
+// ID in the supplied classes, or adds it to them, as mergeLayer does
+func mergeClass(classes map[string]*types.Class, oc *types.Class, layer int) {
+	c := classes[oc.ID]
+	if c == nil {
+		setClassLayer(oc, layer)
+		classes[oc.ID] = oc
+		return
+	}
+	rename(&c.Name, &c.Comments, &c.Layer, oc.Name, oc.Comments, layer)
+	added := false
+	for _, osc := range oc.Subclasses {
+		sc := findByID(c.Subclasses, subclassID, osc.ID)
+		if sc == nil {
+			setSubclassLayer(osc, layer)
+			osc.Class = c
+			c.Subclasses = append(c.Subclasses, osc)
+			added = true
+			continue
+		}
+		rename(&sc.Name, &sc.Comments, &sc.Layer, osc.Name, osc.Comments, layer)
+		addedPI := false
+		for _, opi := range osc.ProgrammingInterfaces {
+			pi := findByID(sc.ProgrammingInterfaces, progIfaceID, opi.ID)
+			if pi == nil {
+				opi.Layer = layer
+				opi.Subclass = sc
+				sc.ProgrammingInterfaces = append(sc.ProgrammingInterfaces, opi)
+				addedPI = true
+				continue
+			}
+			rename(&pi.Name, &pi.Comments, &pi.Layer, opi.Name, opi.Comments, layer)
+		}
+		if addedPI {
+			sc.ProgrammingInterfaces = sortedByID(sc.ProgrammingInterfaces, progIfaceID)
+		}
+	}
+	if added {
+		c.Subclasses = sortedByID(c.Subclasses, subclassID)
+	}
+}
+
+// rename replaces the supplied name and comments of an entry with the
+// supplied overlay name and comments, and sets the entry's layer to the
+// supplied layer, if the overlay name is not empty and differs from the name
+func rename(
+	name *string,
+	comments *[]string,
+	entryLayer *int,
+	overlayName string,
+	overlayComments []string,
+	layer int,
+) {
+	if overlayName == "" || overlayName == *name {
+		return
+	}
+	*name = overlayName
+	*comments = overlayComments
+	*entryLayer = layer
+}
+
+// findByID returns the product, subclass or programming interface among the
+// supplied entries with the supplied ID, as returned by the supplied
+// function, or the zero value if there is none
+func findByID[T any](entries []T, id func(T) string, want string) T {
+	for _, entry := range entries {
+		if id(entry) == want {
+			return entry
+		}
+	}
+	var zero T
+	return zero
+}
+
+// findSubsystem returns the subsystem among the supplied subsystems with the
+// supplied subvendor and subdevice IDs, or nil if there is none
+func findSubsystem(
+	subsystems []*types.Subsystem,
+	subvendorID string,
+	subdeviceID string,
+) *types.Subsystem {
+	for _, s := range subsystems {
+		if s.SubvendorID == subvendorID && s.SubdeviceID == subdeviceID {
+			return s
+		}
+	}
+	return nil
+}
+
+func setVendorLayer(v *types.Vendor, layer int) {
+	v.Layer = layer
+	for _, p := range v.Products {
+		setProductLayer(p, layer)
+	}
+}
+
+func setProductLayer(p *types.Product, layer int) {
+	p.Layer = layer
+	for _, s := range p.Subsystems {
+		s.Layer = layer
+	}
+}
+
+func setClassLayer(c *types.Class, layer int) {
+	c.Layer = layer
+	for _, sc := range c.Subclasses {
+		setSubclassLayer(sc, layer)
+	}
+}
+
+func setSubclassLayer(sc *types.Subclass, layer int) {
+	sc.Layer = layer
+	for _, pi := range sc.ProgrammingInterfaces {
+		pi.Layer = layer
+	}
+}
@@ -0,0 +1,213 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestOverlays(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	root := t.TempDir()
+	basePath := filepath.Join(root, "usr", "share", "hwdata", "pci.ids")
+	overlayDir := filepath.Join(root, types.DefaultOverlayDir)
+	writeFile := func(path string, lines ...string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Expected no error creating directory, but got %v", err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatalf("Expected no error writing file, but got %v", err)
+		}
+	}
+	writeFile(basePath, string(contents))
+	// Written out of order to check that the directory is read in lexical
+	// order
+	lab := filepath.Join(overlayDir, "20-lab.ids")
+	writeFile(lab,
+		"8086",
+		"\t0b60  Internal FPGA Accelerator v2",
+		"abcd  Example Silicon",
+	)
+	site := filepath.Join(overlayDir, "10-site.ids")
+	writeFile(site,
+		"# Site-local additions",
+		"8086",
+		"\t0b60  Internal FPGA Accelerator",
+		"\t\t8086 0001  FPGA Accelerator Rev A",
+		"1590  HPE",
+		"abcd  Example Silicon Inc.",
+		"\t0001  Pre-release NIC",
+		"C 02",
+		"\t80  Network controller",
+		"\t\t01  Prototype",
+	)
+	writeFile(filepath.Join(overlayDir, "README"), "Not an overlay")
+	extra := filepath.Join(t.TempDir(), "extra.ids")
+	writeFile(extra, "1590  Hewlett Packard Enterprise")
+
+	opts := func(extraOpts ...*types.WithOption) *types.WithOption {
+		return MergeOptions(append([]*types.WithOption{
+			types.WithChroot(root), types.WithCachePath(""),
+		}, extraOpts...)...)
+	}
+	db, err := Load(opts(types.WithOverlay(extra)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+
+	wantLayers := []string{basePath, site, lab, extra}
+	if !reflect.DeepEqual(db.Layers, wantLayers) {
+		t.Fatalf("Expected layers %q but got %q", wantLayers, db.Layers)
+	}
+
+	intel := db.Vendors["8086"]
+	if intel.Name != "Intel Corporation" || intel.Layer != 0 {
+		t.Fatalf("Expected referenced vendor to keep its name and layer, but got %q from layer %d",
+			intel.Name, intel.Layer)
+	}
+	var ids []string
+	for _, p := range intel.Products {
+		ids = append(ids, p.ID)
+	}
+	if want := []string{"0b60", "10f8", "1572"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Expected products %q but got %q", want, ids)
+	}
+	fpga := db.Products["80860b60"]
+	if fpga == nil || fpga.Name != "Internal FPGA Accelerator v2" || fpga.Layer != 2 {
+		t.Fatalf("Expected product renamed by the later layer, but got %+v", fpga)
+	}
+	if fpga.Vendor != intel {
+		t.Fatalf("Expected added product to belong to the base vendor")
+	}
+	rev := db.Subsystems["80860b6080860001"]
+	if rev == nil || rev.Layer != 1 || rev.Product != fpga || rev.Subvendor != intel {
+		t.Fatalf("Expected linked subsystem from the first layer, but got %+v", rev)
+	}
+	if layer := db.Products["808610f8"].Layer; layer != 0 {
+		t.Fatalf("Expected base product to be from layer 0 but got %d", layer)
+	}
+
+	hpe := db.Vendors["1590"]
+	if hpe.Name != "Hewlett Packard Enterprise" || hpe.Layer != 3 {
+		t.Fatalf("Expected WithOverlay to take precedence over the directory, but got %q from layer %d",
+			hpe.Name, hpe.Layer)
+	}
+	example := db.Vendors["abcd"]
+	if example == nil || example.Name != "Example Silicon" || example.Layer != 2 {
+		t.Fatalf("Expected added vendor renamed by the later layer, but got %+v", example)
+	}
+	if nic := db.Products["abcd0001"]; nic == nil || nic.Layer != 1 || nic.Vendor != example {
+		t.Fatalf("Expected product of added vendor from layer 1, but got %+v", nic)
+	}
+
+	network := db.Classes["02"]
+	if network.Name != "Network controller" || network.Layer != 0 {
+		t.Fatalf("Expected referenced class to keep its name and layer, but got %q from layer %d",
+			network.Name, network.Layer)
+	}
+	other := db.Subclasses["0280"]
+	if other == nil || other.Layer != 1 || other.Class != network {
+		t.Fatalf("Expected added subclass from layer 1, but got %+v", other)
+	}
+	if pi := db.ProgrammingInterfaces["028001"]; pi == nil || pi.Subclass != other {
+		t.Fatalf("Expected added programming interface, but got %+v", pi)
+	}
+
+	again, err := Load(opts(types.WithOverlay(extra)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if again != db {
+		t.Fatalf("Expected equivalent loads with overlays to share a single DB")
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(site, later, later); err != nil {
+		t.Fatalf("Expected no error changing mtime, but got %v", err)
+	}
+	changed, err := Load(opts(types.WithOverlay(extra)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if changed == db {
+		t.Fatalf("Expected a modified overlay to be applied again")
+	}
+
+	// The base DB loaded on its own is unaffected by the overlays, and an
+	// explicit path skips the overlay directory
+	base, err := Load(MergeOptions(types.WithPath(basePath)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if base.Layers != nil || base.Products["80860b60"] != nil || base.Vendors["1590"].Name != "Hewlett Packard Enterprise" {
+		t.Fatalf("Expected the base DB without overlays")
+	}
+	only, err := Load(MergeOptions(types.WithPath(basePath), types.WithOverlay(extra)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if want := []string{basePath, extra}; !reflect.DeepEqual(only.Layers, want) {
+		t.Fatalf("Expected layers %q but got %q", want, only.Layers)
+	}
+
+	missing := filepath.Join(root, "missing.ids")
+	if _, err := Load(opts(types.WithOverlay(missing))); !os.IsNotExist(err) {
+		t.Fatalf("Expected a not-exist error for a missing overlay, but got %v", err)
+	}
+}
+
+func TestOverlaysUnreadableDir(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	root := t.TempDir()
+	basePath := filepath.Join(root, "usr", "share", "hwdata", "pci.ids")
+	if err := os.MkdirAll(filepath.Dir(basePath), 0o755); err != nil {
+		t.Fatalf("Expected no error creating directory, but got %v", err)
+	}
+	if err := os.WriteFile(basePath, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	// A regular file in place of the overlay directory can't be read as one,
+	// even by root, unlike a directory without read permission
+	overlayDir := filepath.Join(root, types.DefaultOverlayDir)
+	if err := os.MkdirAll(filepath.Dir(overlayDir), 0o755); err != nil {
+		t.Fatalf("Expected no error creating directory, but got %v", err)
+	}
+	if err := os.WriteFile(overlayDir, []byte("8086  Not a directory\n"), 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+
+	alerter := &recordingAlerter{}
+	opts := MergeOptions(
+		types.WithChroot(root), types.WithCachePath(""),
+		types.WithAlerter(alerter), types.WithDisableMemoization(),
+	)
+	db, err := Load(opts)
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if db.Layers != nil || db.Vendors["8086"].Name != "Intel Corporation" {
+		t.Fatalf("Expected the base DB without overlays, but got layers %q", db.Layers)
+	}
+	if len(alerter.alerts) != 1 || !strings.Contains(alerter.alerts[0], overlayDir) {
+		t.Fatalf("Expected a warning about %s, but got %q", overlayDir, alerter.alerts)
+	}
+	if _, err := LoadLazy(opts); err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+}
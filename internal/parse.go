@@ -7,171 +7,480 @@
 package internal
 
 import (
-	"bufio"
 	"io"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/jaypipes/pcidb/types"
 )
 
+// entryKind is the kind of entry a line in a pci-ids DB file describes
+type entryKind int
+
+const (
+	kindNone entryKind = iota
+	kindClass
+	kindSubclass
+	kindProgIface
+	kindVendor
+	kindProduct
+	kindSubsystem
+	// kindSkipped is the header line of a vendor or class block that is
+	// excluded by a filter
+	kindSkipped
+)
+
+// entryCounts holds the number of each kind of entry in a pci-ids DB file
+type entryCounts [kindSkipped + 1]int
+
 // FromReader reads the supplied io.ReadCloser representing a PCIIDS database
 // file or gzipped database file and returns a populated pcidb.DB with parsed
 // PCI product, vendor and class information.
+//
+// The whole file is read into a single string and every ID and name in the
+// returned DB is a substring of it, so parsing does not allocate a string per
+// entry. A first pass over the file counts each kind of entry so that all
+// structs, slices and maps are allocated once, at their final size.
 func FromReader(
 	f io.ReadCloser,
 ) *types.DB {
+	return fromReader(f, nil)
+}
+
+// fromReader reads and parses the supplied pci-ids DB file, only keeping the
+// entries the supplied filter allows
+func fromReader(f io.ReadCloser, flt *filter) *types.DB {
 	defer f.Close()
-	scanner := bufio.NewScanner(f)
-	inClassBlock := false
-	classes := make(map[string]*types.Class, 20)
-	vendors := make(map[string]*types.Vendor, 200)
-	products := make(map[string]*types.Product, 1000)
-	subclasses := make([]*types.Subclass, 0)
-	progIfaces := make([]*types.ProgrammingInterface, 0)
+	return parseText(readAll(f), flt)
+}
+
+// parseText parses the supplied pci-ids DB file contents, which may be a
+// whole file or any sequence of complete vendor and class blocks, only
+// keeping the entries the supplied filter allows
+func parseText(text string, flt *filter) *types.DB {
+	var counts entryCounts
+	var keptLen int
+	keepComments := flt.keepComments()
+	inClassBlock, skipping := false, false
+	for rest := text; rest != ""; {
+		var line string
+		line, rest = nextLine(rest)
+		var kind entryKind
+		kind, inClassBlock = classify(line, inClassBlock)
+		kind, skipping = flt.apply(kind, line, skipping)
+		counts[kind]++
+		if kind != kindNone && kind != kindSkipped ||
+			keepComments && kind == kindNone && isComment(line) {
+			keptLen += len(line)
+		}
+	}
+
+	classes := make(map[string]*types.Class, counts[kindClass])
+	subclasses := make(map[string]*types.Subclass, counts[kindSubclass])
+	progIfaces := make(map[string]*types.ProgrammingInterface, counts[kindProgIface])
+	vendors := make(map[string]*types.Vendor, counts[kindVendor])
+	products := make(map[string]*types.Product, counts[kindProduct])
+	subsystems := make(map[string]*types.Subsystem, counts[kindSubsystem])
+
+	// Each kind of entry is allocated from a single slab, and the child
+	// slices of each parent entry are carved out of a single slice of
+	// pointers, since children always directly follow their parent.
+	classSlab := make([]types.Class, counts[kindClass])
+	subclassSlab := make([]types.Subclass, counts[kindSubclass])
+	progIfaceSlab := make([]types.ProgrammingInterface, counts[kindProgIface])
+	vendorSlab := make([]types.Vendor, counts[kindVendor])
+	productSlab := make([]types.Product, counts[kindProduct])
+	subsystemSlab := make([]types.Subsystem, counts[kindSubsystem])
+	subclassPtrs := make([]*types.Subclass, counts[kindSubclass])
+	progIfacePtrs := make([]*types.ProgrammingInterface, counts[kindProgIface])
+	productPtrs := make([]*types.Product, counts[kindProduct])
+	subsystemPtrs := make([]*types.Subsystem, counts[kindSubsystem])
+	// Keys of the flat maps are the IDs of an entry and its parents, for
+	// example the vendor ID followed by the product ID. They are all written
+	// to one buffer that never grows, so each key can be a substring of the
+	// buffer's contents.
+	var keys strings.Builder
+	keys.Grow(4*counts[kindSubclass] + 6*counts[kindProgIface] +
+		8*counts[kindProduct] + 16*counts[kindSubsystem])
+	key := func(ids ...string) string {
+		start := keys.Len()
+		for _, id := range ids {
+			keys.WriteString(id)
+		}
+		return keys.String()[start:keys.Len()]
+	}
+	// With a filter, the lines that are kept are copied to one buffer that
+	// never grows, so that a DB holding a few entries doesn't keep the whole
+	// file's contents alive.
+	var kept strings.Builder
+	keep := func(line string) string { return line }
+	if flt != nil {
+		kept.Grow(keptLen)
+		keep = func(line string) string {
+			start := kept.Len()
+			kept.WriteString(line)
+			return kept.String()[start:kept.Len()]
+		}
+	}
+	var nClasses, nSubclasses, nProgIfaces int
+	var nVendors, nProducts, nSubsystems int
+
 	var curClass *types.Class
 	var curSubclass *types.Subclass
-	var curProgIface *types.ProgrammingInterface
-	vendorProducts := make([]*types.Product, 0)
 	var curVendor *types.Vendor
 	var curProduct *types.Product
-	var curSubsystem *types.Product
-	productSubsystems := make([]*types.Product, 0)
-	for scanner.Scan() {
-		line := scanner.Text()
-		// skip comments and blank lines
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		lineBytes := []rune(line)
-
-		// Lines starting with an uppercase "C" indicate a PCI top-level class
-		// dbrmation block. These lines look like this:
-		//
-		// C 02  Network controller
-		if lineBytes[0] == 'C' {
-			if curClass != nil {
-				// finalize existing class because we found a new class block
-				curClass.Subclasses = subclasses
-				subclasses = make([]*types.Subclass, 0)
+	var classStart, subclassStart, vendorStart, productStart int
+	// comments are the comment lines since the last entry or blank line,
+	// which are attached to the next entry
+	var comments, curComments []string
+
+	// finalize the children of the current entries because we found a new
+	// block at the same or a higher level
+	finishSubclass := func() {
+		if curSubclass != nil {
+			curSubclass.ProgrammingInterfaces = progIfacePtrs[subclassStart:nProgIfaces:nProgIfaces]
+			curSubclass = nil
+		}
+	}
+	finishClass := func() {
+		finishSubclass()
+		if curClass != nil {
+			curClass.Subclasses = subclassPtrs[classStart:nSubclasses:nSubclasses]
+			curClass = nil
+		}
+	}
+	finishProduct := func() {
+		if curProduct != nil {
+			curProduct.Subsystems = subsystemPtrs[productStart:nSubsystems:nSubsystems]
+			curProduct = nil
+		}
+	}
+	finishVendor := func() {
+		finishProduct()
+		if curVendor != nil {
+			curVendor.Products = productPtrs[vendorStart:nProducts:nProducts]
+			curVendor = nil
+		}
+	}
+
+	inClassBlock, skipping = false, false
+	for rest := text; rest != ""; {
+		var line string
+		line, rest = nextLine(rest)
+		var kind entryKind
+		kind, inClassBlock = classify(line, inClassBlock)
+		kind, skipping = flt.apply(kind, line, skipping)
+		if keepComments {
+			if kind == kindNone && isComment(line) {
+				comments = append(comments, commentText(keep(line)))
+				continue
 			}
-			inClassBlock = true
-			classID := string(lineBytes[2:4])
-			className := string(lineBytes[6:])
-			curClass = &types.Class{
-				ID:         classID,
-				Name:       className,
-				Subclasses: subclasses,
+			// Entries claim the preceding comments, which blank lines and
+			// skipped lines discard
+			if kind != kindNone {
+				curComments = comments
 			}
+			comments = nil
+		}
+		if kind != kindNone && kind != kindSkipped {
+			line = keep(line)
+		}
+		switch kind {
+		case kindSkipped:
+			finishVendor()
+			finishClass()
+		case kindClass:
+			// C 02  Network controller
+			finishVendor()
+			finishClass()
+			curClass = &classSlab[nClasses]
+			nClasses++
+			curClass.ID = line[2:4]
+			curClass.NumericID = types.ClassID(hexValue(curClass.ID))
+			curClass.Name = nameFrom(line, 6)
+			curClass.Comments = curComments
+			classStart = nSubclasses
 			classes[curClass.ID] = curClass
-			continue
-		}
-
-		// Lines not beginning with an uppercase "C" or a TAB character
-		// indicate a top-level vendor dbrmation block. These lines look like
-		// this:
-		//
-		// 0a89  BREA Technologies Inc
-		if lineBytes[0] != '\t' {
-			if curVendor != nil {
-				// finalize existing vendor because we found a new vendor block
-				curVendor.Products = vendorProducts
-				vendorProducts = make([]*types.Product, 0)
+		case kindSubclass:
+			// \t00  Non-VGA unclassified device
+			if curClass == nil {
+				continue
 			}
-			inClassBlock = false
-			vendorID := string(lineBytes[0:4])
-			vendorName := string(lineBytes[6:])
-			curVendor = &types.Vendor{
-				ID:       vendorID,
-				Name:     vendorName,
-				Products: vendorProducts,
+			finishSubclass()
+			curSubclass = &subclassSlab[nSubclasses]
+			curSubclass.ID = line[1:3]
+			curSubclass.Name = nameFrom(line, 5)
+			curSubclass.Comments = curComments
+			curSubclass.Class = curClass
+			subclassPtrs[nSubclasses] = curSubclass
+			subclasses[key(curClass.ID, curSubclass.ID)] = curSubclass
+			nSubclasses++
+			subclassStart = nProgIfaces
+		case kindProgIface:
+			// \t\t00  UHCI
+			if curSubclass == nil {
+				continue
 			}
+			progIface := &progIfaceSlab[nProgIfaces]
+			progIface.ID = line[2:4]
+			progIface.Name = nameFrom(line, 6)
+			progIface.Comments = curComments
+			progIface.Subclass = curSubclass
+			progIfacePtrs[nProgIfaces] = progIface
+			progIfaces[key(curClass.ID, curSubclass.ID, progIface.ID)] = progIface
+			nProgIfaces++
+		case kindVendor:
+			// 0a89  BREA Technologies Inc
+			finishVendor()
+			curVendor = &vendorSlab[nVendors]
+			nVendors++
+			curVendor.ID = line[0:4]
+			curVendor.NumericID = types.VendorID(hexValue(curVendor.ID))
+			curVendor.Name = nameFrom(line, 6)
+			curVendor.Comments = curComments
+			vendorStart = nProducts
 			vendors[curVendor.ID] = curVendor
-			continue
-		}
-
-		// Lines beginning with only a single TAB character are *either* a
-		// subclass OR are a device dbrmation block. If we're in a class
-		// block (i.e. the last parsed block header was for a PCI class), then
-		// we parse a subclass block. Otherwise, we parse a device dbrmation
-		// block.
-		//
-		// A subclass dbrmation block looks like this:
-		//
-		// \t00  Non-VGA unclassified device
-		//
-		// A device dbrmation block looks like this:
-		//
-		// \t0002  PCI to MCA Bridge
-		if len(lineBytes) > 1 && lineBytes[1] != '\t' {
-			if inClassBlock {
-				if curSubclass != nil {
-					// finalize existing subclass because we found a new subclass block
-					curSubclass.ProgrammingInterfaces = progIfaces
-					progIfaces = make([]*types.ProgrammingInterface, 0)
-				}
-				subclassID := string(lineBytes[1:3])
-				subclassName := string(lineBytes[5:])
-				curSubclass = &types.Subclass{
-					ID:                    subclassID,
-					Name:                  subclassName,
-					ProgrammingInterfaces: progIfaces,
-				}
-				subclasses = append(subclasses, curSubclass)
-			} else {
-				if curProduct != nil {
-					// finalize existing product because we found a new product block
-					curProduct.Subsystems = productSubsystems
-					productSubsystems = make([]*types.Product, 0)
-				}
-				productID := string(lineBytes[1:5])
-				productName := string(lineBytes[7:])
-				productKey := curVendor.ID + productID
-				curProduct = &types.Product{
-					VendorID: curVendor.ID,
-					ID:       productID,
-					Name:     productName,
-				}
-				vendorProducts = append(vendorProducts, curProduct)
-				products[productKey] = curProduct
+		case kindProduct:
+			// \t0002  PCI to MCA Bridge
+			if curVendor == nil {
+				continue
 			}
-		} else {
-			// Lines beginning with two TAB characters are *either* a subsystem
-			// (subdevice) OR are a programming interface for a PCI device
-			// subclass. If we're in a class block (i.e. the last parsed block
-			// header was for a PCI class), then we parse a programming
-			// interface block, otherwise we parse a subsystem block.
-			//
-			// A programming interface block looks like this:
-			//
-			// \t\t00  UHCI
-			//
-			// A subsystem block looks like this:
-			//
+			finishProduct()
+			curProduct = &productSlab[nProducts]
+			curProduct.VendorID = curVendor.ID
+			curProduct.NumericVendorID = curVendor.NumericID
+			curProduct.Vendor = curVendor
+			curProduct.ID = line[1:5]
+			curProduct.NumericID = types.DeviceID(hexValue(curProduct.ID))
+			curProduct.Name = nameFrom(line, 7)
+			curProduct.Comments = curComments
+			productPtrs[nProducts] = curProduct
+			nProducts++
+			productStart = nSubsystems
+			products[key(curVendor.ID, curProduct.ID)] = curProduct
+		case kindSubsystem:
 			// \t\t0e11 4091  Smart Array 6i
-			if inClassBlock {
-				progIfaceID := string(lineBytes[2:4])
-				progIfaceName := string(lineBytes[6:])
-				curProgIface = &types.ProgrammingInterface{
-					ID:   progIfaceID,
-					Name: progIfaceName,
-				}
-				progIfaces = append(progIfaces, curProgIface)
-			} else {
-				vendorID := string(lineBytes[2:6])
-				subsystemID := string(lineBytes[7:11])
-				subsystemName := string(lineBytes[13:])
-				curSubsystem = &types.Product{
-					VendorID: vendorID,
-					ID:       subsystemID,
-					Name:     subsystemName,
-				}
-				productSubsystems = append(productSubsystems, curSubsystem)
+			if curProduct == nil {
+				continue
 			}
+			subsystem := &subsystemSlab[nSubsystems]
+			subsystem.SubvendorID = line[2:6]
+			subsystem.NumericSubvendorID = types.VendorID(hexValue(subsystem.SubvendorID))
+			subsystem.SubdeviceID = line[7:11]
+			subsystem.NumericSubdeviceID = types.DeviceID(hexValue(subsystem.SubdeviceID))
+			subsystem.Name = nameFrom(line, 13)
+			subsystem.Comments = curComments
+			subsystem.Product = curProduct
+			subsystem.VendorID = subsystem.SubvendorID
+			subsystem.ID = subsystem.SubdeviceID
+			subsystemPtrs[nSubsystems] = subsystem
+			subsystems[key(
+				curVendor.ID, curProduct.ID,
+				subsystem.SubvendorID, subsystem.SubdeviceID,
+			)] = subsystem
+			nSubsystems++
 		}
 	}
+	finishVendor()
+	finishClass()
+	for x := range subsystemSlab[:nSubsystems] {
+		subsystem := &subsystemSlab[x]
+		subsystem.Subvendor = vendors[subsystem.SubvendorID]
+	}
 	return &types.DB{
-		Classes:  classes,
-		Products: products,
-		Vendors:  vendors,
+		Classes:               classes,
+		Products:              products,
+		Vendors:               vendors,
+		Subclasses:            subclasses,
+		ProgrammingInterfaces: progIfaces,
+		Subsystems:            subsystems,
+		SubvendorSubsystems:   indexSubvendors(subsystemPtrs[:nSubsystems]),
+	}
+}
+
+// indexDB builds the flat maps of the supplied DB from its vendors and
+// classes
+func indexDB(db *types.DB) {
+	db.Products = map[string]*types.Product{}
+	db.Subsystems = map[string]*types.Subsystem{}
+	for _, v := range db.Vendors {
+		for _, p := range v.Products {
+			db.Products[v.ID+p.ID] = p
+			for _, s := range p.Subsystems {
+				db.Subsystems[v.ID+p.ID+s.SubvendorID+s.SubdeviceID] = s
+			}
+		}
+	}
+	// Vendors are visited in ID order, as they appear in the pci-ids DB file,
+	// so that subvendor index groups are ordered as they are when parsed
+	vendorIDs := make([]string, 0, len(db.Vendors))
+	for id := range db.Vendors {
+		vendorIDs = append(vendorIDs, id)
+	}
+	sort.Strings(vendorIDs)
+	all := make([]*types.Subsystem, 0, len(db.Subsystems))
+	for _, id := range vendorIDs {
+		for _, p := range db.Vendors[id].Products {
+			all = append(all, p.Subsystems...)
+		}
+	}
+	db.SubvendorSubsystems = indexSubvendors(all)
+	db.Subclasses = map[string]*types.Subclass{}
+	db.ProgrammingInterfaces = map[string]*types.ProgrammingInterface{}
+	for _, c := range db.Classes {
+		for _, sc := range c.Subclasses {
+			db.Subclasses[c.ID+sc.ID] = sc
+			for _, pi := range sc.ProgrammingInterfaces {
+				db.ProgrammingInterfaces[c.ID+sc.ID+pi.ID] = pi
+			}
+		}
+	}
+}
+
+// indexSubvendors returns the supplied subsystems grouped by subvendor ID,
+// keeping their order within each group. The groups share a single backing
+// array.
+func indexSubvendors(subsystems []*types.Subsystem) map[string][]*types.Subsystem {
+	counts := map[string]int{}
+	for _, s := range subsystems {
+		counts[s.SubvendorID]++
+	}
+	all := make([]*types.Subsystem, len(subsystems))
+	index := make(map[string][]*types.Subsystem, len(counts))
+	start := 0
+	for id, n := range counts {
+		index[id] = all[start : start : start+n]
+		start += n
+	}
+	for _, s := range subsystems {
+		index[s.SubvendorID] = append(index[s.SubvendorID], s)
+	}
+	return index
+}
+
+// readAll returns the contents of the supplied reader as a string. As with a
+// bufio.Scanner, a read error simply truncates the DB.
+func readAll(r io.Reader) string {
+	text, _ := readText(r)
+	return text
+}
+
+// readText returns the contents of the supplied reader as a string, sizing
+// the buffer up front when the reader is a file, along with any read error,
+// such as io.ErrUnexpectedEOF for a truncated gzip-compressed file
+func readText(r io.Reader) (string, error) {
+	var b strings.Builder
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil {
+			b.Grow(int(fi.Size()))
+		}
+	}
+	_, err := io.Copy(&b, r)
+	return b.String(), err
+}
+
+// nextLine returns the first line in the supplied text, without any trailing
+// line ending, and the remaining text
+func nextLine(text string) (line string, rest string) {
+	if x := strings.IndexByte(text, '\n'); x >= 0 {
+		line, rest = text[:x], text[x+1:]
+	} else {
+		line = text
+	}
+	return strings.TrimSuffix(line, "\r"), rest
+}
+
+// classify returns the kind of entry the supplied line describes and whether
+// subsequent indented lines belong to a class block. Comments, blank lines and
+// lines too short to hold the entry's IDs are kindNone.
+//
+// Lines starting with an uppercase "C" indicate a PCI top-level class
+// information block. Lines not beginning with an uppercase "C" or a TAB
+// character indicate a top-level vendor information block.
+//
+// Lines beginning with only a single TAB character are *either* a subclass OR
+// are a device information block, and lines beginning with two TAB characters
+// are *either* a programming interface for a PCI device subclass OR a
+// subsystem (subdevice), depending on whether the last parsed block header was
+// for a PCI class.
+func classify(line string, inClassBlock bool) (entryKind, bool) {
+	if line == "" || line[0] == '#' {
+		return kindNone, inClassBlock
+	}
+	switch {
+	case line[0] == 'C' && len(line) > 1 && line[1] == ' ':
+		if len(line) < 4 {
+			return kindNone, true
+		}
+		return kindClass, true
+	case line[0] != '\t':
+		if len(line) < 4 {
+			return kindNone, false
+		}
+		return kindVendor, false
+	case len(line) > 1 && line[1] != '\t':
+		if inClassBlock {
+			if len(line) < 3 {
+				return kindNone, inClassBlock
+			}
+			return kindSubclass, inClassBlock
+		}
+		if len(line) < 5 {
+			return kindNone, inClassBlock
+		}
+		return kindProduct, inClassBlock
+	default:
+		if inClassBlock {
+			if len(line) < 4 {
+				return kindNone, inClassBlock
+			}
+			return kindProgIface, inClassBlock
+		}
+		if len(line) < 11 {
+			return kindNone, inClassBlock
+		}
+		return kindSubsystem, inClassBlock
+	}
+}
+
+// hexValue returns the value of the supplied hex-encoded ID, treating any
+// character that is not a hex digit as zero
+func hexValue(id string) uint32 {
+	var v uint32
+	for x := 0; x < len(id); x++ {
+		c := id[x]
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			c = 0
+		}
+		v = v<<4 | uint32(c)
+	}
+	return v
+}
+
+// isComment returns whether the supplied line is a comment
+func isComment(line string) bool {
+	return line != "" && line[0] == '#'
+}
+
+// commentText returns the text of the supplied comment line, without the
+// leading "#" and the single space that usually follows it
+func commentText(line string) string {
+	return strings.TrimPrefix(line[1:], " ")
+}
+
+// nameFrom returns the name in the supplied line starting at the supplied
+// offset, or an empty string if the line is too short
+func nameFrom(line string, offset int) string {
+	if len(line) <= offset {
+		return ""
 	}
+	return line[offset:]
 }
@@ -0,0 +1,111 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/internal"
+	"github.com/jaypipes/pcidb/pcidbtest"
+)
+
+// syntheticDB returns pci-ids DB file contents roughly the size and shape of
+// the real pci.ids database: ~2,500 vendors, ~30,000 products and ~20,000
+// subsystems, followed by the class block
+func syntheticDB() string {
+	var b strings.Builder
+	b.WriteString("#\n#\tList of PCI ID's\n#\n#\tVersion: 2025.08.20\n#\n\n")
+	for v := 0; v < 2500; v++ {
+		fmt.Fprintf(&b, "%04x  Vendor number %d Corporation\n", v+0x1000, v)
+		for p := 0; p < 12; p++ {
+			fmt.Fprintf(
+				&b, "\t%04x  Product %d [Family %d] Controller\n",
+				p+0x1000, p, v,
+			)
+			for s := 0; s < (v+p)%4; s++ {
+				fmt.Fprintf(
+					&b, "\t\t%04x %04x  Subsystem %d board for product %d\n",
+					(v*7+s)%0xffff, s, s, p,
+				)
+			}
+		}
+	}
+	b.WriteString("\n# List of known device classes\n\n")
+	for c := 0; c < 20; c++ {
+		fmt.Fprintf(&b, "C %02x  Class %d\n", c, c)
+		for sc := 0; sc < 8; sc++ {
+			fmt.Fprintf(&b, "\t%02x  Subclass %d\n", sc, sc)
+			for pi := 0; pi < 3; pi++ {
+				fmt.Fprintf(&b, "\t\t%02x  Programming interface %d\n", pi, pi)
+			}
+		}
+	}
+	return b.String()
+}
+
+func benchmarkFromReader(b *testing.B, contents string) {
+	b.SetBytes(int64(len(contents)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		internal.FromReader(io.NopCloser(strings.NewReader(contents)))
+	}
+}
+
+func BenchmarkFromReaderSynthetic(b *testing.B) {
+	benchmarkFromReader(b, syntheticDB())
+}
+
+func BenchmarkFromReaderFixture(b *testing.B) {
+	benchmarkFromReader(b, pcidbtest.Basic)
+}
+
+// BenchmarkFromReaderHost benchmarks parsing the host's pci.ids database file,
+// if one can be found
+func BenchmarkFromReaderHost(b *testing.B) {
+	f, err := internal.Discover(internal.MergeOptions())
+	if err != nil {
+		b.Skipf("Skipping, no host pci.ids database file: %v", err)
+	}
+	contents, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		b.Fatalf("Expected no error reading pci.ids, but got %v", err)
+	}
+	benchmarkFromReader(b, string(contents))
+}
+
+func BenchmarkCompiledLookup(b *testing.B) {
+	contents := syntheticDB()
+	db := internal.FromReader(io.NopCloser(strings.NewReader(contents)))
+	path := filepath.Join(b.TempDir(), "pci.ids.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatalf("Expected no error creating file, but got %v", err)
+	}
+	if err := internal.WriteCompiled(f, db, internal.CompiledSource{}); err != nil {
+		b.Fatalf("Expected no error compiling DB, but got %v", err)
+	}
+	f.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for x := 0; x < b.N; x++ {
+		c, err := internal.OpenCompiled(path)
+		if err != nil {
+			b.Fatalf("Expected no error opening compiled DB, but got %v", err)
+		}
+		if c.Product("1400", "1005") == nil {
+			b.Fatalf("Expected to find product 1400:1005")
+		}
+		c.Close()
+	}
+}
@@ -7,18 +7,20 @@
 package internal_test
 
 import (
-	"os"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/jaypipes/pcidb"
+	"github.com/jaypipes/pcidb/internal"
+	"github.com/jaypipes/pcidb/pcidbtest"
 	"github.com/jaypipes/pcidb/types"
 )
 
 func TestParse(t *testing.T) {
-	if _, ok := os.LookupEnv("GHW_TESTING_SKIP_PCI"); ok {
-		t.Skip("Skipping PCI tests.")
-	}
-	db, err := pcidb.New()
+	chroot := pcidbtest.NewChroot(t)
+	chroot.WriteHwdata(pcidbtest.Basic)
+	db, err := pcidb.New(chroot.Option())
 	if err != nil {
 		t.Fatalf("Expected no error creating pcidb.DB, but got %v", err)
 	}
@@ -131,3 +133,86 @@ func TestParse(t *testing.T) {
 		t.Fatalf("Failed to find NetRAID subsystem in MegaRAID product subsystems array.")
 	}
 }
+
+func TestParseEdgeCases(t *testing.T) {
+	contents := strings.Join([]string{
+		"# comment",
+		"\t",
+		"\t\t",
+		"0e11  Compaq Computer Corporation\r",
+		"\t0001  PCI to EISA Bridge\r",
+		"\t\t0e11 4091  Smart Array 6i\r",
+		"\t\t0e1",
+		"8086  Intel Corporation",
+		"\t10f8  82599 10 Gigabit Dual Port Backplane Connection",
+		"\t\t8086 000c  Ethernet X520 10GbE Dual Port KX4-KR Mezz",
+		"C 0c  Serial bus controller",
+		"\t03  USB controller",
+		"\t\t30  XHCI",
+	}, "\n")
+	db := internal.FromReader(io.NopCloser(strings.NewReader(contents)))
+
+	compaq, exists := db.Vendors["0e11"]
+	if !exists {
+		t.Fatalf("Expected to find Compaq vendor")
+	}
+	if compaq.Name != "Compaq Computer Corporation" {
+		t.Fatalf("Expected trailing CR to be stripped but got %q", compaq.Name)
+	}
+	if len(compaq.Products) != 1 || len(compaq.Products[0].Subsystems) != 1 {
+		t.Fatalf("Expected malformed subsystem line to be skipped")
+	}
+
+	// The last vendor, product, class and subclass blocks are finalized at
+	// the start of the class block and at the end of the file
+	intelInc := db.Vendors["8086"]
+	if len(intelInc.Products) != 1 {
+		t.Fatalf("Expected 1 product for last vendor, but got %d", len(intelInc.Products))
+	}
+	if len(intelInc.Products[0].Subsystems) != 1 {
+		t.Fatalf("Expected 1 subsystem for last product, but got %d", len(intelInc.Products[0].Subsystems))
+	}
+	usb := db.Classes["0c"].Subclasses[0]
+	if len(usb.ProgrammingInterfaces) != 1 || usb.ProgrammingInterfaces[0].Name != "XHCI" {
+		t.Fatalf("Expected XHCI programming interface for last subclass")
+	}
+	if db.Products["808610f8"] != intelInc.Products[0] {
+		t.Fatalf("Expected products map and vendor products to share entries")
+	}
+}
+
+func TestParseFlatIndexes(t *testing.T) {
+	db := pcidbtest.FromText(pcidbtest.Basic)
+
+	massStorage := db.Classes["01"]
+	if sc := db.Subclasses["0108"]; sc == nil || sc != massStorage.Subclasses[2] {
+		t.Fatalf("Expected subclass 0108 to be the class's third subclass, but got %+v", sc)
+	}
+	if pi := db.ProgrammingInterfaces["010802"]; pi == nil || pi != massStorage.Subclasses[2].ProgrammingInterfaces[1] {
+		t.Fatalf("Expected programming interface 010802 in flat index, but got %+v", pi)
+	}
+	if len(db.Subclasses) != 7 || len(db.ProgrammingInterfaces) != 9 {
+		t.Fatalf("Expected 7 subclasses and 9 programming interfaces, but got %d and %d",
+			len(db.Subclasses), len(db.ProgrammingInterfaces))
+	}
+	netRaid := db.Subsystems["101e1960103c60e7"]
+	if netRaid == nil || netRaid != db.Products["101e1960"].Subsystems[2] {
+		t.Fatalf("Expected subsystem 101e1960103c60e7 in flat index, but got %+v", netRaid)
+	}
+
+	lazy, err := internal.LoadLazy(internal.MergeOptions(
+		types.WithPath(pcidbtest.WriteFile(t, pcidbtest.Basic)),
+	))
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	lazyDB := lazy.DB()
+	if len(lazyDB.Subsystems) != len(db.Subsystems) ||
+		len(lazyDB.Subclasses) != len(db.Subclasses) ||
+		len(lazyDB.ProgrammingInterfaces) != len(db.ProgrammingInterfaces) {
+		t.Fatalf("Expected lazy DB flat indexes to match parsed DB")
+	}
+	if lazyDB.Subsystems["101e1960103c60e7"] == nil {
+		t.Fatalf("Expected subsystem 101e1960103c60e7 in lazy DB flat index")
+	}
+}
@@ -0,0 +1,82 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestParentReferences(t *testing.T) {
+	fixture := basicFixture
+	db, err := Load(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	for key, p := range db.Products {
+		if p.Vendor != db.Vendors[p.VendorID] {
+			t.Fatalf("Expected product %s to refer to vendor %s, but got %+v", key, p.VendorID, p.Vendor)
+		}
+	}
+	for _, c := range db.Classes {
+		for _, sc := range c.Subclasses {
+			if sc.Class != c {
+				t.Fatalf("Expected subclass %s to refer to class %s", sc.ID, c.ID)
+			}
+			for _, pi := range sc.ProgrammingInterfaces {
+				if pi.Subclass != sc {
+					t.Fatalf("Expected programming interface %s to refer to subclass %s", pi.ID, sc.ID)
+				}
+			}
+		}
+	}
+	if _, err := json.Marshal(db); err != nil {
+		t.Fatalf("Expected no error marshaling DB with parent references, but got %v", err)
+	}
+
+	lazy, err := LoadLazy(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	if p := lazy.Product("8086", "1572"); p.Vendor != lazy.Vendor("8086") {
+		t.Fatalf("Expected lazy product to refer to its vendor, but got %+v", p.Vendor)
+	}
+	if sc := lazy.Class("0c").Subclasses[1]; sc.Class != lazy.Class("0c") {
+		t.Fatalf("Expected lazy subclass to refer to its class, but got %+v", sc.Class)
+	}
+
+	path := filepath.Join(t.TempDir(), "pci.ids.bin")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating file, but got %v", err)
+	}
+	err = WriteCompiled(out, db, CompiledSource{})
+	out.Close()
+	if err != nil {
+		t.Fatalf("Expected no error compiling DB, but got %v", err)
+	}
+	c, err := OpenCompiled(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening compiled DB, but got %v", err)
+	}
+	defer c.Close()
+	if p := c.Product("8086", "1572"); p.Vendor == nil || p.Vendor.Name != db.Vendors["8086"].Name {
+		t.Fatalf("Expected compiled product to refer to its vendor, but got %+v", p.Vendor)
+	}
+	v := c.Vendor("8086")
+	if v.Products[1].Vendor != v {
+		t.Fatalf("Expected compiled vendor's products to refer to it")
+	}
+	cls := c.Class("0c")
+	if sc := cls.Subclasses[1]; sc.Class != cls || sc.ProgrammingInterfaces[0].Subclass != sc {
+		t.Fatalf("Expected compiled subclasses and programming interfaces to refer to their parents")
+	}
+}
@@ -0,0 +1,149 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestSearch(t *testing.T) {
+	db, err := Load(MergeOptions(types.WithPath(basicFixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+
+	hits, err := db.Search("connectx-6", nil)
+	if err != nil {
+		t.Fatalf("Expected no error searching, but got %v", err)
+	}
+	want := []string{"15b3101b", "15b3101b15b30006", "15b3101d", "15b3101d10280009"}
+	if len(hits) != len(want) {
+		t.Fatalf("Expected %d hits but got %+v", len(want), hits)
+	}
+	for x, hit := range hits {
+		if hit.ID != want[x] {
+			t.Fatalf("Expected hit %d to be %s but got %s", x, want[x], hit.ID)
+		}
+	}
+	if hits[0].Kind != types.KindProduct || hits[0].Product != db.Products["15b3101b"] ||
+		hits[0].Vendor != db.Vendors["15b3"] {
+		t.Fatalf("Expected first hit to be the MT28908 product, but got %+v", hits[0])
+	}
+	span := hits[0].Spans[0]
+	if len(hits[0].Spans) != 1 || hits[0].Name[span[0]:span[1]] != "ConnectX-6" {
+		t.Fatalf("Expected span of ConnectX-6, but got %v", hits[0].Spans)
+	}
+	if hits[3].Kind != types.KindSubsystem || hits[3].Subsystem.SubvendorID != "1028" {
+		t.Fatalf("Expected last hit to be the Dell subsystem, but got %+v", hits[3])
+	}
+
+	hits, err = db.Search("dx CONNECTX", &types.SearchOptions{
+		Mode:  types.SearchTokens,
+		Kinds: []types.EntityKind{types.KindProduct},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error searching, but got %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != "15b3101d" || len(hits[0].Spans) != 2 {
+		t.Fatalf("Expected token search to only match the Dx product, but got %+v", hits)
+	}
+
+	hits, err = db.Search(`^(U|O)HCI$`, &types.SearchOptions{
+		Mode:    types.SearchRegexp,
+		ClassID: "0C",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error searching, but got %v", err)
+	}
+	if len(hits) != 2 || hits[0].ID != "0c0010" || hits[1].ID != "0c0300" ||
+		hits[1].Kind != types.KindProgrammingInterface {
+		t.Fatalf("Expected regexp search to match the OHCI and UHCI interfaces, but got %+v", hits)
+	}
+	if hits[1].Subclass != db.Subclasses["0c03"] {
+		t.Fatalf("Expected hit to have its subclass, but got %+v", hits[1].Subclass)
+	}
+
+	hits, _ = db.Search("corporation", &types.SearchOptions{VendorID: "8086"})
+	if len(hits) != 1 || hits[0].Kind != types.KindVendor || hits[0].Vendor.ID != "8086" {
+		t.Fatalf("Expected vendor-scoped search to only match Intel, but got %+v", hits)
+	}
+	hits, _ = db.Search("controller", &types.SearchOptions{Limit: 2})
+	if len(hits) != 2 {
+		t.Fatalf("Expected 2 hits with a limit of 2, but got %d", len(hits))
+	}
+	if _, err := db.Search("(", &types.SearchOptions{Mode: types.SearchRegexp}); err == nil {
+		t.Fatalf("Expected an error for an invalid regular expression")
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	db, err := Load(MergeOptions(types.WithPath(basicFixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+
+	tests := []struct {
+		query string
+		mode  types.SearchMode
+	}{
+		{"", types.SearchSubstring},
+		{"", types.SearchRegexp},
+		{"^", types.SearchRegexp},
+		{"$|^", types.SearchRegexp},
+		{"", types.SearchTokens},
+		{" - ", types.SearchTokens},
+	}
+	for _, test := range tests {
+		hits, err := db.Search(test.query, &types.SearchOptions{Mode: test.mode})
+		if err != nil {
+			t.Fatalf("Expected no error searching for %q, but got %v", test.query, err)
+		}
+		if len(hits) != 0 {
+			t.Fatalf("Expected no hits for %q in mode %d, but got %+v", test.query, test.mode, hits)
+		}
+	}
+
+	// Patterns that can match the empty string only match names with a
+	// non-empty match
+	hits, err := db.Search("x*", &types.SearchOptions{Mode: types.SearchRegexp})
+	if err != nil {
+		t.Fatalf("Expected no error searching, but got %v", err)
+	}
+	if len(hits) == 0 {
+		t.Fatalf("Expected hits for names containing an x")
+	}
+	for _, hit := range hits {
+		if len(hit.Spans) == 0 {
+			t.Fatalf("Expected spans for hit %s", hit.ID)
+		}
+		for _, span := range hit.Spans {
+			if match := hit.Name[span[0]:span[1]]; span[0] >= span[1] || match[0] != 'x' && match[0] != 'X' {
+				t.Fatalf("Expected only non-empty spans of x, but got %v in %q", span, hit.Name)
+			}
+		}
+	}
+}
+
+func TestSearchCaseFolding(t *testing.T) {
+	db := parseText("1234  Ärger Technik\n", nil)
+
+	// Every mode folds non-ASCII letters the way the regexp mode does
+	modes := []types.SearchMode{types.SearchSubstring, types.SearchRegexp, types.SearchTokens}
+	for _, mode := range modes {
+		for _, query := range []string{"ärger", "ÄRGER", "äRgEr"} {
+			hits, err := db.Search(query, &types.SearchOptions{Mode: mode})
+			if err != nil {
+				t.Fatalf("Expected no error searching for %q, but got %v", query, err)
+			}
+			if len(hits) != 1 || len(hits[0].Spans) != 1 || hits[0].Spans[0] != [2]int{0, 6} {
+				t.Fatalf("Expected a single hit spanning Ärger for %q in mode %d, but got %+v", query, mode, hits)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestSubsystems(t *testing.T) {
+	fixture := basicFixture
+	db, err := Load(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	megaRaid := db.Products["101e1960"]
+	netRaid := megaRaid.Subsystems[2]
+	if netRaid.SubvendorID != "103c" || netRaid.SubdeviceID != "60e7" {
+		t.Fatalf("Expected subsystem 103c:60e7, but got %+v", netRaid)
+	}
+	if netRaid.Product != megaRaid {
+		t.Fatalf("Expected subsystem to refer to its parent product")
+	}
+	if netRaid.Subvendor != db.Vendors["103c"] {
+		t.Fatalf("Expected subsystem to refer to its subvendor, but got %+v", netRaid.Subvendor)
+	}
+	// 1590 is a vendor without products; 15b3 0006 is the product's own
+	// vendor
+	mellanox := db.Products["15b3101b"].Subsystems
+	if mellanox[0].Subvendor != db.Vendors["15b3"] || mellanox[1].Subvendor != db.Vendors["1590"] {
+		t.Fatalf("Expected subvendors 15b3 and 1590, but got %+v", mellanox)
+	}
+
+	lazy, err := LoadLazy(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	lazyNetRaid := lazy.Product("101e", "1960").Subsystems[2]
+	if lazyNetRaid.Subvendor == nil || lazyNetRaid.Subvendor.Name != db.Vendors["103c"].Name {
+		t.Fatalf("Expected lazy subsystem to refer to its subvendor, but got %+v", lazyNetRaid.Subvendor)
+	}
+	if lazy.Vendor("103c") != lazyNetRaid.Subvendor || len(lazyNetRaid.Subvendor.Products) != 1 {
+		t.Fatalf("Expected looking up the subvendor to complete it in place")
+	}
+
+	path := filepath.Join(t.TempDir(), "pci.ids.bin")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating file, but got %v", err)
+	}
+	err = WriteCompiled(out, db, CompiledSource{})
+	out.Close()
+	if err != nil {
+		t.Fatalf("Expected no error compiling DB, but got %v", err)
+	}
+	c, err := OpenCompiled(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening compiled DB, but got %v", err)
+	}
+	defer c.Close()
+	compiled := c.Product("101e", "1960")
+	if s := compiled.Subsystems[2]; s.Product != compiled || s.Subvendor == nil || s.Subvendor.ID != "103c" {
+		t.Fatalf("Expected compiled subsystem to refer to its product and subvendor, but got %+v", s)
+	}
+	ami := c.Vendor("101e")
+	if s := ami.Products[0].Subsystems[0]; s.Subvendor != ami {
+		t.Fatalf("Expected compiled subsystem to refer to the looked up vendor, but got %+v", s.Subvendor)
+	}
+	full := c.DB()
+	if s := full.Products["101e1960"].Subsystems[2]; s.Subvendor != full.Vendors["103c"] {
+		t.Fatalf("Expected compiled DB subsystem to refer to its subvendor, but got %+v", s.Subvendor)
+	}
+}
+
+func TestSubsystemJSON(t *testing.T) {
+	var current, legacy types.Subsystem
+	err := json.Unmarshal([]byte(`{"subvendor_id":"103c","subdevice_id":"60e7","name":"NetRAID-1M"}`), &current)
+	if err != nil {
+		t.Fatalf("Expected no error unmarshaling subsystem, but got %v", err)
+	}
+	err = json.Unmarshal([]byte(`{"vendor_id":"103c","id":"60e7","name":"NetRAID-1M"}`), &legacy)
+	if err != nil {
+		t.Fatalf("Expected no error unmarshaling legacy subsystem, but got %v", err)
+	}
+	for _, s := range []types.Subsystem{current, legacy} {
+		if s.SubvendorID != "103c" || s.SubdeviceID != "60e7" ||
+			s.VendorID != "103c" || s.ID != "60e7" ||
+			s.NumericSubvendorID != 0x103c || s.NumericSubdeviceID != 0x60e7 {
+			t.Fatalf("Expected subsystem 103c:60e7, but got %+v", s)
+		}
+	}
+
+	b, err := json.Marshal(&current)
+	if err != nil {
+		t.Fatalf("Expected no error marshaling subsystem, but got %v", err)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("Expected no error unmarshaling subsystem fields, but got %v", err)
+	}
+	if fields["vendor_id"] != "103c" || fields["id"] != "60e7" || fields["subvendor_id"] != "103c" {
+		t.Fatalf("Expected both current and legacy fields in JSON, but got %s", b)
+	}
+}
+
+func TestSubsystemsBySubvendor(t *testing.T) {
+	fixture := basicFixture
+	db, err := Load(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	want := []string{"101e1960", "10280001", "15b3101d", "808610f8", "80861572"}
+	check := func(name string, dell []*types.Subsystem) {
+		t.Helper()
+		if len(dell) != len(want) {
+			t.Fatalf("Expected %d %s subsystems for subvendor 1028, but got %d", len(want), name, len(dell))
+		}
+		for x, s := range dell {
+			if s.SubvendorID != "1028" || s.Product.VendorID+s.Product.ID != want[x] {
+				t.Fatalf("Expected %s subsystem %d to be a 1028 subsystem of %s, but got %+v", name, x, want[x], s)
+			}
+		}
+	}
+	check("parsed", db.SubsystemsBySubvendor("1028"))
+	if db.SubsystemsBySubvendorID(0x1028)[0] != db.Subsystems["101e196010280471"] {
+		t.Fatalf("Expected subvendor index to share entries with the subsystems map")
+	}
+	if len(db.SubsystemsBySubvendor("1590")) != 1 || db.SubsystemsBySubvendor("dead") != nil {
+		t.Fatalf("Expected 1 subsystem for 1590 and none for an unknown subvendor")
+	}
+
+	lazy, err := LoadLazy(MergeOptions(types.WithPath(fixture)))
+	if err != nil {
+		t.Fatalf("Expected no error loading lazy DB, but got %v", err)
+	}
+	check("lazy", lazy.DB().SubsystemsBySubvendor("1028"))
+
+	path := filepath.Join(t.TempDir(), "pci.ids.bin")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating file, but got %v", err)
+	}
+	err = WriteCompiled(out, db, CompiledSource{})
+	out.Close()
+	if err != nil {
+		t.Fatalf("Expected no error compiling DB, but got %v", err)
+	}
+	c, err := OpenCompiled(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening compiled DB, but got %v", err)
+	}
+	defer c.Close()
+	compiled := c.SubsystemsBySubvendor("1028")
+	check("compiled", compiled)
+	if compiled[1].Subvendor != compiled[1].Product.Vendor {
+		t.Fatalf("Expected compiled subsystem of a 1028 product to share its vendor")
+	}
+	check("compiled DB", c.DB().SubsystemsBySubvendor("1028"))
+}
@@ -0,0 +1,181 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// TableOptions configures the delimited tables written by the Write*Table
+// functions
+type TableOptions struct {
+	// Delimiter separates the fields of each row. The zero value uses a
+	// comma, and '\t' writes tab-separated tables. Fields that contain the
+	// delimiter, a double quote or a line break are quoted.
+	Delimiter rune
+	// NoHeader leaves out the header row of column names
+	NoHeader bool
+}
+
+// tableWriter writes the rows of a delimited table, remembering the first
+// error encountered so that callers only need to check it once
+type tableWriter struct {
+	w   *csv.Writer
+	err error
+}
+
+// newTableWriter returns a tableWriter for the supplied options that has
+// written the supplied column names, unless the options leave them out
+func newTableWriter(w io.Writer, opts TableOptions, columns ...string) *tableWriter {
+	tw := &tableWriter{w: csv.NewWriter(w)}
+	if opts.Delimiter != 0 {
+		tw.w.Comma = opts.Delimiter
+	}
+	if !opts.NoHeader {
+		tw.row(columns...)
+	}
+	return tw
+}
+
+func (tw *tableWriter) row(fields ...string) {
+	if tw.err == nil {
+		tw.err = tw.w.Write(fields)
+	}
+}
+
+// finish flushes the table and returns the first error writing it
+func (tw *tableWriter) finish() error {
+	tw.w.Flush()
+	if tw.err != nil {
+		return tw.err
+	}
+	return tw.w.Error()
+}
+
+// WriteVendorsTable writes one row per vendor, sorted by vendor ID, with the
+// columns vendor_id and vendor_name.
+func WriteVendorsTable(w io.Writer, db *types.DB, opts TableOptions) error {
+	tw := newTableWriter(w, opts, "vendor_id", "vendor_name")
+	for _, vid := range sortedIDs(db.Vendors) {
+		v := db.Vendors[vid]
+		tw.row(v.ID, v.Name)
+	}
+	return tw.finish()
+}
+
+// WriteProductsTable writes one row per product, sorted by vendor and product
+// ID, with the columns vendor_id, product_id, vendor_name and product_name.
+func WriteProductsTable(w io.Writer, db *types.DB, opts TableOptions) error {
+	tw := newTableWriter(w, opts, "vendor_id", "product_id", "vendor_name", "product_name")
+	for _, vid := range sortedIDs(db.Vendors) {
+		v := db.Vendors[vid]
+		for _, p := range sortedByID(v.Products, productID) {
+			tw.row(v.ID, p.ID, v.Name, p.Name)
+		}
+	}
+	return tw.finish()
+}
+
+// WriteSubsystemsTable writes one row per subsystem, sorted by vendor and
+// product ID and then in the order they are listed, with the columns
+// vendor_id, product_id, subvendor_id, subdevice_id, vendor_name,
+// product_name, subvendor_name and subsystem_name. The subvendor name is
+// empty if the DB doesn't contain the subvendor.
+func WriteSubsystemsTable(w io.Writer, db *types.DB, opts TableOptions) error {
+	tw := newTableWriter(
+		w, opts,
+		"vendor_id", "product_id", "subvendor_id", "subdevice_id",
+		"vendor_name", "product_name", "subvendor_name", "subsystem_name",
+	)
+	for _, vid := range sortedIDs(db.Vendors) {
+		v := db.Vendors[vid]
+		for _, p := range sortedByID(v.Products, productID) {
+			for _, s := range p.Subsystems {
+				subvendorName := ""
+				if sv := db.Vendors[s.SubvendorID]; sv != nil {
+					subvendorName = sv.Name
+				}
+				tw.row(
+					v.ID, p.ID, s.SubvendorID, s.SubdeviceID,
+					v.Name, p.Name, subvendorName, s.Name,
+				)
+			}
+		}
+	}
+	return tw.finish()
+}
+
+// WriteClassesTable writes one row per class, subclass and programming
+// interface, sorted by ID, with the columns code, class_id, subclass_id,
+// prog_if_id, class_name, subclass_name and prog_if_name. The code is the
+// class ID for a class ("0c"), the class and subclass IDs for a subclass
+// ("0c03") and all three IDs for a programming interface ("0c0330"), which is
+// the full class code that a device reports. The columns for the levels
+// below a row's own are empty.
+func WriteClassesTable(w io.Writer, db *types.DB, opts TableOptions) error {
+	tw := newTableWriter(
+		w, opts,
+		"code", "class_id", "subclass_id", "prog_if_id",
+		"class_name", "subclass_name", "prog_if_name",
+	)
+	for _, cid := range sortedIDs(db.Classes) {
+		c := db.Classes[cid]
+		tw.row(c.ID, c.ID, "", "", c.Name, "", "")
+		for _, sc := range sortedByID(c.Subclasses, subclassID) {
+			tw.row(c.ID+sc.ID, c.ID, sc.ID, "", c.Name, sc.Name, "")
+			for _, pi := range sortedByID(sc.ProgrammingInterfaces, progIfaceID) {
+				tw.row(
+					c.ID+sc.ID+pi.ID, c.ID, sc.ID, pi.ID,
+					c.Name, sc.Name, pi.Name,
+				)
+			}
+		}
+	}
+	return tw.finish()
+}
+
+// WriteTables writes the vendors, products, subsystems and classes tables of
+// the supplied DB to the files vendors, products, subsystems and classes in
+// the supplied directory, which is created if needed. The files have a .tsv
+// extension if the delimiter is a tab and a .csv extension otherwise.
+func WriteTables(dir string, db *types.DB, opts TableOptions) error {
+	ext := ".csv"
+	if opts.Delimiter == '\t' {
+		ext = ".tsv"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tables := []struct {
+		name  string
+		write func(io.Writer, *types.DB, TableOptions) error
+	}{
+		{"vendors", WriteVendorsTable},
+		{"products", WriteProductsTable},
+		{"subsystems", WriteSubsystemsTable},
+		{"classes", WriteClassesTable},
+	}
+	for _, table := range tables {
+		f, err := os.Create(filepath.Join(dir, table.name+ext))
+		if err != nil {
+			return err
+		}
+		if err := table.write(f, db, opts); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// readTable writes a table of the supplied DB with the supplied function and
+// returns its rows
+func readTable(
+	t *testing.T,
+	db *types.DB,
+	write func(io.Writer, *types.DB, TableOptions) error,
+	opts TableOptions,
+) [][]string {
+	var out bytes.Buffer
+	if err := write(&out, db, opts); err != nil {
+		t.Fatalf("Expected no error writing table, but got %v", err)
+	}
+	r := csv.NewReader(&out)
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("Expected table to be valid, but got %v", err)
+	}
+	return rows
+}
+
+// findRow returns the first of the supplied rows that starts with the
+// supplied fields, or nil if there is none
+func findRow(rows [][]string, fields ...string) []string {
+	for _, row := range rows {
+		if len(row) >= len(fields) && reflect.DeepEqual(row[:len(fields)], fields) {
+			return row
+		}
+	}
+	return nil
+}
+
+func TestTables(t *testing.T) {
+	f, err := openDBFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error opening fixture, but got %v", err)
+	}
+	db := FromReader(f)
+
+	vendors := readTable(t, db, WriteVendorsTable, TableOptions{})
+	if len(vendors) != len(db.Vendors)+1 || !reflect.DeepEqual(vendors[0], []string{"vendor_id", "vendor_name"}) {
+		t.Fatalf("Expected header and one row per vendor, but got %q", vendors)
+	}
+	if !reflect.DeepEqual(vendors[1], []string{"0e11", "Compaq Computer Corporation"}) {
+		t.Fatalf("Expected vendors sorted by ID, but got %q", vendors[1])
+	}
+
+	products := readTable(t, db, WriteProductsTable, TableOptions{})
+	if len(products) != len(db.Products)+1 {
+		t.Fatalf("Expected one row per product, but got %d rows", len(products))
+	}
+	if row := findRow(products, "101e", "1960"); !reflect.DeepEqual(row, []string{"101e", "1960", "American Megatrends Inc.", "MegaRAID"}) {
+		t.Fatalf("Expected MegaRAID row with vendor name, but got %q", row)
+	}
+
+	subsystems := readTable(t, db, WriteSubsystemsTable, TableOptions{})
+	if len(subsystems) != len(db.Subsystems)+1 {
+		t.Fatalf("Expected one row per subsystem, but got %d rows", len(subsystems))
+	}
+	want := []string{
+		"101e", "1960", "103c", "60e7",
+		"American Megatrends Inc.", "MegaRAID", "Hewlett-Packard Company", "NetRAID-1M",
+	}
+	if row := findRow(subsystems, "101e", "1960", "103c"); !reflect.DeepEqual(row, want) {
+		t.Fatalf("Expected NetRAID-1M row with both vendor names, but got %q", row)
+	}
+
+	classes := readTable(t, db, WriteClassesTable, TableOptions{Delimiter: '\t', NoHeader: true})
+	if len(classes) != len(db.Classes)+len(db.Subclasses)+len(db.ProgrammingInterfaces) {
+		t.Fatalf("Expected one row per class, subclass and programming interface, but got %d", len(classes))
+	}
+	if !reflect.DeepEqual(classes[0], []string{"01", "01", "", "", "Mass storage controller", "", ""}) {
+		t.Fatalf("Expected first class row without header, but got %q", classes[0])
+	}
+	want = []string{"0c0330", "0c", "03", "30", "Serial bus controller", "USB controller", "XHCI"}
+	if row := findRow(classes, "0c0330"); !reflect.DeepEqual(row, want) {
+		t.Fatalf("Expected XHCI row with full class code, but got %q", row)
+	}
+	want = []string{"010180", "01", "01", "80", "Mass storage controller", "IDE interface",
+		"ISA Compatibility mode-only controller, supports bus mastering"}
+	if row := findRow(classes, "010180"); !reflect.DeepEqual(row, want) {
+		t.Fatalf("Expected name with comma to be read back whole, but got %q", row)
+	}
+
+	var out bytes.Buffer
+	if err := WriteVendorsTable(&out, db, TableOptions{Delimiter: '"'}); err == nil {
+		t.Fatalf("Expected error for invalid delimiter")
+	}
+
+	dir := filepath.Join(t.TempDir(), "tables")
+	if err := WriteTables(dir, db, TableOptions{Delimiter: '\t'}); err != nil {
+		t.Fatalf("Expected no error writing tables, but got %v", err)
+	}
+	for _, name := range []string{"vendors.tsv", "products.tsv", "subsystems.tsv", "classes.tsv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to be written, but got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// Watcher holds a DB that is reloaded whenever the pci-ids DB file it was
+// loaded from changes.
+type Watcher struct {
+	opts     *types.WithOption
+	interval time.Duration
+	db       atomic.Pointer[types.DB]
+	// the resolved path, size and modification time of the pci-ids DB file
+	// that the current DB was loaded from, and the stamp of its overlays.
+	// Only accessed by the polling goroutine after Watch returns.
+	path     string
+	size     int64
+	modTime  time.Time
+	overlays string
+
+	lock sync.Mutex
+	subs []chan *types.DB
+	done bool
+}
+
+// Watch loads a DB as described by the supplied options and starts polling
+// the resolved pci-ids DB file and its overlays for changes until the supplied
+// context is cancelled. When any of them changes, the DB is loaded again and
+// the new DB atomically replaces the old one.
+func Watch(ctx context.Context, opts *types.WithOption) (*Watcher, error) {
+	w := &Watcher{
+		opts:     opts,
+		interval: types.DefaultPollInterval,
+	}
+	if opts.PollInterval != nil && *opts.PollInterval > 0 {
+		w.interval = *opts.PollInterval
+	}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+	go w.poll(ctx)
+	return w, nil
+}
+
+// DB returns the current DB. It is safe to call from multiple goroutines.
+// The returned DB must not be modified.
+func (w *Watcher) DB() *types.DB {
+	return w.db.Load()
+}
+
+// Subscribe returns a channel that receives the new DB each time the pci-ids
+// DB file is reloaded. A subscriber that falls behind only receives the most
+// recent DB. The channel is closed when the Watcher's context is cancelled.
+func (w *Watcher) Subscribe() <-chan *types.DB {
+	ch := make(chan *types.DB, 1)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.done {
+		close(ch)
+		return ch
+	}
+	w.subs = append(w.subs, ch)
+	return ch
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			w.done = true
+			for _, ch := range w.subs {
+				close(ch)
+			}
+			w.subs = nil
+			return
+		case <-ticker.C:
+			changed, err := w.reload()
+			if err != nil {
+				alerter(w.opts).Printf(
+					"pcidb: failed reloading pci-ids DB file %s, "+
+						"keeping previous DB: %v",
+					w.path, err,
+				)
+				continue
+			}
+			if changed {
+				w.notify(w.DB())
+			}
+		}
+	}
+}
+
+// reload loads the DB again if the resolved pci-ids DB file or its overlays
+// differ from the ones the current DB was loaded from, returning whether the
+// DB changed
+func (w *Watcher) reload() (bool, error) {
+	path, err := resolvePath(w.opts)
+	if err != nil {
+		return false, err
+	}
+	overlays := overlayPaths(w.opts)
+	stamp, err := overlayStamp(overlays)
+	if err != nil {
+		return false, err
+	}
+	var fi os.FileInfo
+	if path != "" {
+		if fi, err = os.Stat(path); err != nil {
+			return false, err
+		}
+		if w.DB() != nil && path == w.path && fi.Size() == w.size &&
+			fi.ModTime().Equal(w.modTime) && stamp == w.overlays {
+			return false, nil
+		}
+	} else if w.DB() != nil && stamp == w.overlays {
+		// The current DB was fetched from the network into memory and there
+		// is still no local file to watch.
+		return false, nil
+	}
+	db, err := Load(w.opts)
+	if err != nil {
+		return false, err
+	}
+	w.db.Store(db)
+	w.path = path
+	w.overlays = stamp
+	if fi != nil {
+		w.size = fi.Size()
+		w.modTime = fi.ModTime()
+	}
+	return true, nil
+}
+
+func (w *Watcher) notify(db *types.DB) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, ch := range w.subs {
+		// drop any DB the subscriber has not yet received in favour of the
+		// newest one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- db
+	}
+}
@@ -0,0 +1,75 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestWatch(t *testing.T) {
+	contents, err := os.ReadFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error reading fixture, but got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "pci.ids")
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := Watch(ctx, MergeOptions(
+		types.WithPath(path), types.WithPollInterval(10*time.Millisecond),
+	))
+	if err != nil {
+		t.Fatalf("Expected no error watching DB, but got %v", err)
+	}
+	if w.DB().Vendors["8086"].Name != "Intel Corporation" {
+		t.Fatalf("Expected to find Intel vendor in watched DB")
+	}
+	updates := w.Subscribe()
+
+	renamed := strings.Replace(
+		string(contents), "Intel Corporation", "Intel Corp.", 1,
+	)
+	if err := os.WriteFile(path, []byte(renamed), 0o644); err != nil {
+		t.Fatalf("Expected no error writing file, but got %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Expected no error changing mtime, but got %v", err)
+	}
+
+	select {
+	case db := <-updates:
+		if db.Vendors["8086"].Name != "Intel Corp." {
+			t.Fatalf("Expected reloaded DB to have renamed Intel vendor")
+		}
+		if w.DB() != db {
+			t.Fatalf("Expected DB() to return the reloaded DB")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for DB reload")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatalf("Expected no further reloads after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for subscription to close")
+	}
+}
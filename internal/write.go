@@ -0,0 +1,190 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// TextHeader holds the values written to the header comment block of a
+// pci-ids DB file
+type TextHeader struct {
+	// Version is the value of the "Version:" header comment, for example
+	// "2025.08.20". The line is omitted if Version is empty.
+	Version string
+	// Date is the value of the "Date:" header comment, for example
+	// "2025-08-20 03:15:02". The line is omitted if Date is empty.
+	Date string
+}
+
+// WriteText writes the supplied DB in the pci-ids DB file format, in the
+// canonical layout of the upstream pci.ids file: a header comment block, the
+// vendors with their products and subsystems, then the classes with their
+// subclasses and programming interfaces. Every ID is written in lowercase and
+// every entry is sorted by ID, except subsystems, which are written in the
+// order they are listed since the upstream file doesn't sort them either.
+// Parsing the written file with FromReader gives back the same DB, and
+// writing that DB again gives back the same file.
+//
+// The comments of each entry are written directly above it, so that they are
+// attached to it again when parsed with comments kept.
+//
+// An error wrapping types.ErrInvalidID is returned if an ID is not the
+// correct number of hex digits, and one wrapping types.ErrInvalidDB if a
+// name or comment contains a line break.
+func WriteText(w io.Writer, db *types.DB, header TextHeader) error {
+	tw := &textWriter{w: bufio.NewWriter(w)}
+
+	tw.comment("")
+	tw.comment("\tList of PCI ID's")
+	tw.comment("")
+	if header.Version != "" {
+		tw.comment("\tVersion: " + header.Version)
+	}
+	if header.Date != "" {
+		tw.comment("\tDate:    " + header.Date)
+	}
+	if header.Version != "" || header.Date != "" {
+		tw.comment("")
+	}
+	tw.blank()
+	tw.comment(" Vendors, devices and subsystems. Please keep sorted.")
+	tw.blank()
+	tw.comment(" Syntax:")
+	tw.comment(" vendor  vendor_name")
+	tw.comment("\tdevice  device_name\t\t\t\t<-- single tab")
+	tw.comment("\t\tsubvendor subdevice  subsystem_name\t<-- two tabs")
+	tw.blank()
+	for _, vid := range sortedIDs(db.Vendors) {
+		v := db.Vendors[vid]
+		tw.entry(tw.id(v.ID, 4), v.Name, v.Comments)
+		for _, p := range sortedByID(v.Products, productID) {
+			tw.entry("\t"+tw.id(p.ID, 4), p.Name, p.Comments)
+			for _, s := range p.Subsystems {
+				tw.entry("\t\t"+tw.id(s.SubvendorID, 4)+" "+tw.id(s.SubdeviceID, 4), s.Name, s.Comments)
+			}
+		}
+	}
+
+	tw.blank()
+	tw.comment(" List of known device classes, subclasses and programming interfaces")
+	tw.blank()
+	tw.comment(" Syntax:")
+	tw.comment(" C class\tclass_name")
+	tw.comment("\tsubclass\tsubclass_name  \t\t<-- single tab")
+	tw.comment("\t\tprog-if  prog-if_name  \t<-- two tabs")
+	tw.blank()
+	for _, cid := range sortedIDs(db.Classes) {
+		c := db.Classes[cid]
+		tw.entry("C "+tw.id(c.ID, 2), c.Name, c.Comments)
+		for _, sc := range sortedByID(c.Subclasses, subclassID) {
+			tw.entry("\t"+tw.id(sc.ID, 2), sc.Name, sc.Comments)
+			for _, pi := range sortedByID(sc.ProgrammingInterfaces, progIfaceID) {
+				tw.entry("\t\t"+tw.id(pi.ID, 2), pi.Name, pi.Comments)
+			}
+		}
+	}
+	if tw.err != nil {
+		return tw.err
+	}
+	return tw.w.Flush()
+}
+
+// textWriter writes the lines of a pci-ids DB file, remembering the first
+// error encountered so that callers only need to check it once
+type textWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// id returns the lowercase form of the supplied hex-encoded ID, which must
+// have the supplied number of digits
+func (tw *textWriter) id(id string, width int) string {
+	normalized, err := normalizeID(id, width)
+	if err != nil {
+		if tw.err == nil {
+			tw.err = err
+		}
+		return id
+	}
+	return normalized
+}
+
+func (tw *textWriter) comment(text string) {
+	tw.line("#" + text)
+}
+
+func (tw *textWriter) blank() {
+	tw.line("")
+}
+
+// entry writes the supplied comment lines followed by an entry line made of
+// the supplied IDs, indented as needed, two spaces and the supplied name
+func (tw *textWriter) entry(ids string, name string, comments []string) {
+	for _, text := range comments {
+		tw.checkLineBreaks(ids, text)
+		if text == "" {
+			tw.comment("")
+		} else {
+			tw.comment(" " + text)
+		}
+	}
+	tw.checkLineBreaks(ids, name)
+	tw.line(ids + "  " + name)
+}
+
+// checkLineBreaks records an error if the supplied name or comment of the
+// entry with the supplied IDs contains a line break, which would split it
+// across lines
+func (tw *textWriter) checkLineBreaks(ids string, text string) {
+	if strings.ContainsAny(text, "\r\n") && tw.err == nil {
+		tw.err = fmt.Errorf(
+			"%w: entry %q has a name or comment with a line break: %q",
+			types.ErrInvalidDB, strings.TrimSpace(ids), text,
+		)
+	}
+}
+
+func (tw *textWriter) line(text string) {
+	if tw.err != nil {
+		return
+	}
+	tw.w.WriteString(text)
+	tw.err = tw.w.WriteByte('\n')
+}
+
+// sortedIDs returns the keys of the supplied map of vendors or classes in
+// ascending order of their numeric value
+func sortedIDs[V any](m map[string]V) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return hexValue(ids[i]) < hexValue(ids[j]) })
+	return ids
+}
+
+// sortedByID returns a copy of the supplied products, subclasses or
+// programming interfaces, stably sorted by the numeric value of the IDs the
+// supplied function returns
+func sortedByID[T any](entries []T, id func(T) string) []T {
+	sorted := append([]T(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return hexValue(id(sorted[i])) < hexValue(id(sorted[j]))
+	})
+	return sorted
+}
+
+func productID(p *types.Product) string                 { return p.ID }
+func subclassID(sc *types.Subclass) string              { return sc.ID }
+func progIfaceID(pi *types.ProgrammingInterface) string { return pi.ID }
@@ -0,0 +1,116 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestWriteText(t *testing.T) {
+	f, err := openDBFile(basicFixture)
+	if err != nil {
+		t.Fatalf("Expected no error opening fixture, but got %v", err)
+	}
+	db := FromReader(f)
+
+	header := TextHeader{Version: "2025.08.20", Date: "2025-08-20 03:15:02"}
+	var out bytes.Buffer
+	if err := WriteText(&out, db, header); err != nil {
+		t.Fatalf("Expected no error writing DB, but got %v", err)
+	}
+	written := out.String()
+	if version, date := readHeader(strings.NewReader(written)); version != header.Version || date != header.Date {
+		t.Fatalf("Expected header %+v but got version %q and date %q", header, version, date)
+	}
+	if !strings.Contains(written, "\n101e  American Megatrends Inc.\n\t1960  MegaRAID\n\t\t101e 0471  ") {
+		t.Fatalf("Expected vendor, product and subsystem lines, but got\n%s", written)
+	}
+	if !strings.Contains(written, "\nC 0c  Serial bus controller\n\t00  FireWire (IEEE 1394)\n\t\t00  Generic\n") {
+		t.Fatalf("Expected class, subclass and programming interface lines, but got\n%s", written)
+	}
+
+	// Parsing the written file gives back the same DB, and writing it again
+	// gives back the same file
+	reparsed := FromReader(io.NopCloser(strings.NewReader(written)))
+	want, _ := json.Marshal(db)
+	got, _ := json.Marshal(reparsed)
+	if string(want) != string(got) {
+		t.Fatalf("Expected written DB to parse back to the same DB\nwant: %s\ngot:  %s", want, got)
+	}
+	out.Reset()
+	if err := WriteText(&out, reparsed, header); err != nil {
+		t.Fatalf("Expected no error writing DB, but got %v", err)
+	}
+	if out.String() != written {
+		t.Fatalf("Expected writing a parsed DB to be stable\nwant:\n%s\ngot:\n%s", written, out.String())
+	}
+}
+
+func TestWriteTextCanonical(t *testing.T) {
+	contents := strings.Join([]string{
+		"8086  Intel Corporation",
+		"\t1572  Ethernet Controller X710 for 10GbE SFP+",
+		"\t10F8  82599 10 Gigabit Dual Port Backplane Connection",
+		"\t\t8086 000c  Ethernet X520 10GbE Dual Port KX4-KR Mezz",
+		"\t\t1028 1f63  PowerEdge M610",
+		"0e11  Compaq Computer Corporation",
+		"C 0c  Serial bus controller",
+		"\t03  USB controller",
+		"\t\t30  XHCI",
+		"\t\t00  UHCI",
+		"C 01  Mass storage controller",
+	}, "\n")
+	db := FromReader(io.NopCloser(strings.NewReader(contents)))
+
+	var out bytes.Buffer
+	if err := WriteText(&out, db, TextHeader{}); err != nil {
+		t.Fatalf("Expected no error writing DB, but got %v", err)
+	}
+	var entries []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line != "" && line[0] != '#' {
+			entries = append(entries, line)
+		}
+	}
+	want := []string{
+		"0e11  Compaq Computer Corporation",
+		"8086  Intel Corporation",
+		"\t10f8  82599 10 Gigabit Dual Port Backplane Connection",
+		"\t\t8086 000c  Ethernet X520 10GbE Dual Port KX4-KR Mezz",
+		"\t\t1028 1f63  PowerEdge M610",
+		"\t1572  Ethernet Controller X710 for 10GbE SFP+",
+		"C 01  Mass storage controller",
+		"C 0c  Serial bus controller",
+		"\t03  USB controller",
+		"\t\t00  UHCI",
+		"\t\t30  XHCI",
+	}
+	if strings.Join(entries, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Expected sorted, lowercase entries with subsystems in order\n%s\nbut got\n%s",
+			strings.Join(want, "\n"), strings.Join(entries, "\n"))
+	}
+	if strings.Contains(out.String(), "Version:") {
+		t.Fatalf("Expected no Version header for empty TextHeader")
+	}
+
+	db.Vendors["0e11"].Name = "Compaq\nComputer Corporation"
+	if err := WriteText(io.Discard, db, TextHeader{}); !errors.Is(err, types.ErrInvalidDB) {
+		t.Fatalf("Expected ErrInvalidDB for name with line break, but got %v", err)
+	}
+	db.Vendors["0e11"].Name = "Compaq Computer Corporation"
+	db.Vendors["0e11"].ID = "e11"
+	if err := WriteText(io.Discard, db, TextHeader{}); !errors.Is(err, types.ErrInvalidID) {
+		t.Fatalf("Expected ErrInvalidID for short vendor ID, but got %v", err)
+	}
+}
@@ -7,6 +7,9 @@
 package pcidb
 
 import (
+	"context"
+	"sync"
+
 	"github.com/jaypipes/pcidb/internal"
 	"github.com/jaypipes/pcidb/types"
 )
@@ -14,10 +17,66 @@ import (
 type DB = types.DB
 type Product = types.Product
 type Vendor = types.Vendor
+type Subsystem = types.Subsystem
 type Class = types.Class
 type Subclass = types.Subclass
 type ProgrammingInterface = types.ProgrammingInterface
+type VendorID = types.VendorID
+type DeviceID = types.DeviceID
+type ClassID = types.ClassID
+type EntityKind = types.EntityKind
+type SearchMode = types.SearchMode
+type SearchOptions = types.SearchOptions
+type SearchHit = types.SearchHit
+type Index = types.Index
+type Suggestion = types.Suggestion
+type ChangeType = types.ChangeType
+type Change = types.Change
+type DBDiff = types.DBDiff
+
+const (
+	KindVendor               = types.KindVendor
+	KindProduct              = types.KindProduct
+	KindSubsystem            = types.KindSubsystem
+	KindClass                = types.KindClass
+	KindSubclass             = types.KindSubclass
+	KindProgrammingInterface = types.KindProgrammingInterface
+)
+
+const (
+	SearchSubstring = types.SearchSubstring
+	SearchRegexp    = types.SearchRegexp
+	SearchTokens    = types.SearchTokens
+)
+
+const (
+	ChangeAdded   = types.ChangeAdded
+	ChangeRemoved = types.ChangeRemoved
+	ChangeRenamed = types.ChangeRenamed
+)
+
 type WithOption = types.WithOption
+type Alerter = types.Alerter
+type Cache = internal.Cache
+type Watcher = internal.Watcher
+type Compiled = internal.Compiled
+type Lazy = internal.Lazy
+type CompiledSource = internal.CompiledSource
+type TextHeader = internal.TextHeader
+type TableOptions = internal.TableOptions
+type Builder = internal.Builder
+type CacheEntry = types.CacheEntry
+
+// ParseVendorID parses a hex-encoded PCI vendor ID such as "8086" or
+// "0x8086".
+var ParseVendorID = types.ParseVendorID
+
+// ParseDeviceID parses a hex-encoded PCI device ID such as "10f8" or
+// "0x10f8".
+var ParseDeviceID = types.ParseDeviceID
+
+// ParseClassID parses a hex-encoded PCI class ID such as "02" or "0x02".
+var ParseClassID = types.ParseClassID
 
 // WithChroot overrides the root directory used for discovery of pci-ids
 // database files.
@@ -27,6 +86,27 @@ var WithChroot = types.WithChroot
 // pre-found/pre-fetching pci.ids database files.
 var WithCachePath = types.WithCachePath
 
+// WithSystemCache points pcidb at the shared, system-wide cache file
+// (/var/cache/pcidb/pci.ids) instead of the per-user cache file.
+var WithSystemCache = types.WithSystemCache
+
+// WithCacheCompress stores pci.ids database files fetched from the network
+// gzip-compressed in the pcidb cache.
+var WithCacheCompress = types.WithCacheCompress
+
+// WithCacheRetention sets the number of versioned copies of pci.ids database
+// files that are kept in the pcidb cache. Zero keeps every version.
+var WithCacheRetention = types.WithCacheRetention
+
+// WithVersion loads a specific, previously-cached version of the pci.ids
+// database (for example "2025.08.20") from the versioned cache store instead
+// of discovering the current one.
+var WithVersion = types.WithVersion
+
+// WithExpectedChecksum requires that the uncompressed contents of the pci.ids
+// database file match the supplied hex-encoded SHA-256 digest.
+var WithExpectedChecksum = types.WithExpectedChecksum
+
 // WithCacheOnly disables lookup of pci.ids database files over the network and
 // forces pcidb to only use any pre-cached pci.ids database files in its cache
 // directory.
@@ -44,6 +124,46 @@ var WithDirectPath = WithPath
 // filesystem or the pcidb cache directory.
 var WithEnableNetworkFetch = types.WithEnableNetworkFetch
 
+// WithFetchURL fetches the gzip-compressed pci.ids database file from the
+// supplied URL, such as an internal mirror, when network fetching is enabled.
+var WithFetchURL = types.WithFetchURL
+
+// WithDisableMemoization forces pcidb to parse the pci.ids database file
+// again instead of returning a DB shared with other callers. Use this if you
+// need to modify the returned DB.
+var WithDisableMemoization = types.WithDisableMemoization
+
+// WithPollInterval sets how often a pci.ids database file watched with Watch
+// is checked for changes.
+var WithPollInterval = types.WithPollInterval
+
+// WithAlerter sends warnings about non-fatal conditions, such as being unable
+// to write a network-fetched pci.ids database file to the cache, to the
+// supplied Alerter instead of stderr.
+var WithAlerter = types.WithAlerter
+
+// WithDisableWarnings silences warnings about non-fatal conditions.
+var WithDisableWarnings = types.WithDisableWarnings
+
+// WithVendors only loads the vendors, and their products, with the supplied
+// hex-encoded vendor IDs.
+var WithVendors = types.WithVendors
+
+// WithClasses only loads the classes with the supplied hex-encoded class IDs.
+var WithClasses = types.WithClasses
+
+// WithoutSubsystems skips loading the subsystems of every product.
+var WithoutSubsystems = types.WithoutSubsystems
+
+// WithComments loads the comment lines attached to each vendor, product,
+// subsystem and class into its Comments field.
+var WithComments = types.WithComments
+
+// WithOverlay merges the entries of the pci-ids DB file at the supplied path
+// on top of the DB. Each WithOverlay adds a layer that takes precedence over
+// the ones before it.
+var WithOverlay = types.WithOverlay
+
 // Backward-compat, please refer to the pcidb types.DB type definition
 type PCIDB = types.DB
 
@@ -56,11 +176,142 @@ type PCIDB = types.DB
 //
 // For example, to change the root directory that pcidb uses when discovering
 // pciids DB files, call New(WithChroot("/my/root/override"))
+//
+// Repeated calls to New with equivalent options return the same DB, which is
+// shared with every other caller and must not be modified. Use the
+// WithDisableMemoization option modifier to get a private copy.
 func New(opts ...*types.WithOption) (*types.DB, error) {
 	merged := internal.MergeOptions(opts...)
-	f, err := internal.Discover(merged)
-	if err != nil {
-		return nil, err
+	return internal.Load(merged)
+}
+
+var (
+	defaultLock sync.Mutex
+	defaultDB   *types.DB
+)
+
+// Default returns a process-wide, shared pcidb.DB that is loaded with the
+// default options (and any environs overrides) the first time Default is
+// called. The returned DB must not be modified.
+//
+// Only a successful load is remembered: if loading fails, for example
+// because no pci.ids database file is available yet, Default returns the
+// error and the next call tries to load the DB again.
+func Default() (*types.DB, error) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	if defaultDB == nil {
+		db, err := New()
+		if err != nil {
+			return nil, err
+		}
+		defaultDB = db
 	}
-	return internal.FromReader(f), nil
+	return defaultDB, nil
+}
+
+// NewLazy returns a pointer to a pcidb.Lazy struct, a DB that only indexes
+// where each vendor's block is in the pci.ids database file and parses a
+// vendor's products and subsystems the first time the vendor is looked up.
+// Use it instead of New when only a handful of devices will be looked up.
+// Lookups return the same entries as the DB returned by New.
+//
+// It accepts the same option modifiers as New.
+func NewLazy(opts ...*types.WithOption) (*Lazy, error) {
+	merged := internal.MergeOptions(opts...)
+	return internal.LoadLazy(merged)
+}
+
+// Watch returns a pointer to a pcidb.Watcher struct that holds a DB which is
+// reloaded whenever the pci.ids database file it was loaded from changes, for
+// instance when the hwdata package is upgraded. The file is polled for changes
+// until the supplied context is cancelled.
+//
+// Use Watcher.DB to get the current DB and Watcher.Subscribe to be notified
+// when it is reloaded.
+func Watch(ctx context.Context, opts ...*types.WithOption) (*Watcher, error) {
+	merged := internal.MergeOptions(opts...)
+	return internal.Watch(ctx, merged)
+}
+
+// OpenCompiled opens a DB file in pcidb's compiled binary format, which
+// answers vendor, product and class lookups directly from the (memory-mapped,
+// where supported) file without parsing a pci.ids database file or
+// materialising every vendor and product. The returned Compiled must be closed
+// when no longer needed.
+//
+// The pcidb cache stores the compiled form of the cached pci.ids database file
+// at Cache.CompiledPath.
+var OpenCompiled = internal.OpenCompiled
+
+// WriteCompiled encodes the supplied DB in pcidb's compiled binary format.
+var WriteCompiled = internal.WriteCompiled
+
+// WriteTables writes vendors, products, subsystems and classes tables of the
+// supplied DB, as CSV or, with a tab delimiter, TSV files, to the supplied
+// directory.
+var WriteTables = internal.WriteTables
+
+// WriteVendorsTable writes a delimited table of the vendors in the supplied
+// DB.
+var WriteVendorsTable = internal.WriteVendorsTable
+
+// WriteProductsTable writes a delimited table of the products in the supplied
+// DB, with their vendor names.
+var WriteProductsTable = internal.WriteProductsTable
+
+// WriteSubsystemsTable writes a delimited table of the subsystems in the
+// supplied DB, with their vendor, product and subvendor names.
+var WriteSubsystemsTable = internal.WriteSubsystemsTable
+
+// WriteClassesTable writes a delimited table of the classes, subclasses and
+// programming interfaces in the supplied DB, with their full class codes.
+var WriteClassesTable = internal.WriteClassesTable
+
+// JSONSchemaVersion is the version of the pcidb JSON schema that WriteJSON
+// writes and the highest version FromJSON reads.
+const JSONSchemaVersion = internal.JSONSchemaVersion
+
+// WriteJSON writes the supplied DB as a JSON document that FromJSON can read
+// back. The document is the JSON encoding of the DB plus a "schema_version"
+// field. See schema/pcidb-v1.schema.json.
+var WriteJSON = internal.WriteJSON
+
+// FromJSON returns a pointer to a pcidb.DB read from a JSON document written
+// by WriteJSON or by encoding a DB with encoding/json, with all of its maps
+// and the pointers between its entries rebuilt.
+var FromJSON = internal.FromJSON
+
+// WriteText writes the supplied DB in the pci.ids database file format, with
+// every vendor, product and class sorted by ID, so that it can be read by
+// other tools such as lspci. Parsing the written file gives back the same DB.
+var WriteText = internal.WriteText
+
+// NewBuilder returns a pointer to a pcidb.Builder for an empty DB, which
+// validates and adds, removes or renames vendors, products, subsystems,
+// classes, subclasses and programming interfaces one at a time, keeping the
+// DB's maps consistent.
+var NewBuilder = internal.NewBuilder
+
+// NewBuilderFrom returns a pointer to a pcidb.Builder for a copy of the
+// supplied DB, which is left unmodified.
+var NewBuilderFrom = internal.NewBuilderFrom
+
+// NewIndex returns an Index of the names of the vendors and products in the
+// supplied DB, for ranked, typo-tolerant suggestions as a query is typed.
+var NewIndex = types.NewIndex
+
+// Diff returns the vendors, products, subsystems, classes, subclasses and
+// programming interfaces that were added, removed or renamed between the
+// supplied old and new DBs, for example across a hwdata update.
+var Diff = types.Diff
+
+// NewCache returns a pointer to a pcidb.Cache struct that can be used to list,
+// verify, prune and refresh the pci.ids database files in the pcidb cache.
+//
+// It accepts the same option modifiers as New. For example, to manage the
+// shared, system-wide cache, call NewCache(WithSystemCache())
+func NewCache(opts ...*types.WithOption) *Cache {
+	merged := internal.MergeOptions(opts...)
+	return internal.NewCache(merged)
 }
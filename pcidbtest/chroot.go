@@ -0,0 +1,83 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package pcidbtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// Chroot is a temporary root directory for testing the discovery of pci.ids
+// database files, with its own pcidb cache path, so that neither the host's
+// pci.ids database files nor the user's cache are found.
+type Chroot struct {
+	t   testing.TB
+	dir string
+}
+
+// NewChroot creates an empty Chroot. It is removed when the test finishes.
+func NewChroot(t testing.TB) *Chroot {
+	t.Helper()
+	return &Chroot{t: t, dir: t.TempDir()}
+}
+
+// Dir returns the path of the Chroot's root directory.
+func (c *Chroot) Dir() string {
+	return c.dir
+}
+
+// CachePath returns the path of the pcidb cache file inside the Chroot,
+// which Option sets as the cache path.
+func (c *Chroot) CachePath() string {
+	return filepath.Join(c.dir, "root", ".cache", "pci.ids")
+}
+
+// Option returns an option that discovers pci.ids database files under the
+// Chroot and uses its cache path. It also clears any path set with the
+// PCIDB_PATH environs variable, so that discovery isn't skipped.
+func (c *Chroot) Option() *types.WithOption {
+	chroot, cachePath, path := c.dir, c.CachePath(), ""
+	return &types.WithOption{Chroot: &chroot, CachePath: &cachePath, Path: &path}
+}
+
+// WriteFile writes the supplied pci.ids database file contents to the
+// supplied path relative to the Chroot's root directory, for example
+// "usr/share/misc/pci.ids", and returns the file's full path. The contents are
+// gzip-compressed if the path has a ".gz" suffix. Missing directories are
+// created.
+func (c *Chroot) WriteFile(path string, text string) string {
+	c.t.Helper()
+	fullPath := filepath.Join(c.dir, path)
+	writeFile(c.t, fullPath, text)
+	return fullPath
+}
+
+// WriteHwdata writes the supplied pci.ids database file contents to
+// usr/share/hwdata/pci.ids, the first of the well-known locations searched
+// under the root directory, and returns the file's full path.
+func (c *Chroot) WriteHwdata(text string) string {
+	c.t.Helper()
+	return c.WriteFile(filepath.Join("usr", "share", "hwdata", "pci.ids"), text)
+}
+
+// WriteCache writes the supplied pci.ids database file contents to the
+// Chroot's cache path and returns it.
+func (c *Chroot) WriteCache(text string) string {
+	c.t.Helper()
+	writeFile(c.t, c.CachePath(), text)
+	return c.CachePath()
+}
+
+// WriteOverlay writes the supplied pci.ids fragment to a file with the
+// supplied name, which must have a ".ids" suffix to be merged, in the overlay
+// directory under the Chroot and returns the file's full path.
+func (c *Chroot) WriteOverlay(name string, text string) string {
+	c.t.Helper()
+	return c.WriteFile(filepath.Join(types.DefaultOverlayDir, name), text)
+}
@@ -0,0 +1,106 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package pcidbtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jaypipes/pcidb/types"
+)
+
+// Mirror is a fake pci.ids mirror for testing network fetches: an HTTP server
+// that serves a pci.ids database file gzip-compressed, as pci-ids.ucw.cz
+// does, and counts the requests it receives.
+type Mirror struct {
+	server *httptest.Server
+
+	lock     sync.Mutex
+	data     []byte // gzip-compressed pci.ids database file
+	status   int
+	requests int
+}
+
+// NewMirror starts a Mirror that serves the supplied pci.ids database file
+// contents. The Mirror is shut down when the test finishes.
+func NewMirror(t testing.TB, text string) *Mirror {
+	t.Helper()
+	m := &Mirror{data: gzipText(t, text), status: http.StatusOK}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *Mirror) serve(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.requests++
+	if m.status != http.StatusOK {
+		http.Error(w, http.StatusText(m.status), m.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Write(m.data)
+}
+
+// URL returns the URL that the Mirror serves the pci.ids database file from.
+func (m *Mirror) URL() string {
+	return m.server.URL + "/v2.2/pci.ids.gz"
+}
+
+// Option returns an option that enables network fetching and fetches the
+// pci.ids database file from the Mirror.
+func (m *Mirror) Option() *types.WithOption {
+	url := m.URL()
+	enable := true
+	return &types.WithOption{FetchURL: &url, EnableNetworkFetch: &enable}
+}
+
+// SetText changes the pci.ids database file contents that the Mirror serves,
+// for example to test refreshing a cached copy.
+func (m *Mirror) SetText(t testing.TB, text string) {
+	t.Helper()
+	data := gzipText(t, text)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.data = data
+}
+
+// SetStatus makes the Mirror respond to every request with the supplied HTTP
+// status code, for example http.StatusServiceUnavailable to test fetch
+// failures. Setting http.StatusOK serves the pci.ids database file again.
+func (m *Mirror) SetStatus(status int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.status = status
+}
+
+// Requests returns the number of requests the Mirror has received.
+func (m *Mirror) Requests() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.requests
+}
+
+// gzipText returns the supplied text gzip-compressed, failing the test on
+// error
+func gzipText(t testing.TB, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(text)); err != nil {
+		t.Fatalf("pcidbtest: compressing pci.ids: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("pcidbtest: compressing pci.ids: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,95 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+// Package pcidbtest provides small, curated pci.ids database files and
+// helpers for testing code that uses pcidb without depending on the pci.ids
+// database file installed on the host or on the network: building a DB from
+// inline pci.ids text, a fake pci.ids mirror for network fetches and a
+// temporary root directory for discovery.
+package pcidbtest
+
+import (
+	_ "embed"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/pcidb/internal"
+	"github.com/jaypipes/pcidb/types"
+)
+
+var (
+	// Basic is a pci.ids database file with a cross-section of the upstream
+	// file: its header comments, vendors with and without products,
+	// products with subsystems from other subvendors, a comment attached to
+	// a product and classes with subclasses and programming interfaces. It
+	// includes Intel's 82599 backplane connection (808610f8), the MegaRAID
+	// (101e1960) with its NetRAID-1M subsystem (103c 60e7) and the serial bus
+	// controller class (0c) with its FireWire and USB subclasses.
+	//
+	//go:embed fixtures/basic.ids
+	Basic string
+	// Minimal is a pci.ids database file with a single vendor, product,
+	// subsystem, class and subclass: Intel's 82599 backplane connection
+	// (808610f8) and the Ethernet controller subclass (0200).
+	//
+	//go:embed fixtures/minimal.ids
+	Minimal string
+	// Overlay is a site-local pci.ids fragment to merge on top of Basic or
+	// Minimal as an overlay. It adds a product, with a subsystem, to Intel
+	// (80860b60) without restating Intel's name, and a vendor that isn't in
+	// the upstream file (abcd) with one product.
+	//
+	//go:embed fixtures/overlay.ids
+	Overlay string
+)
+
+// FromText returns the DB parsed from the supplied pci.ids database file
+// contents, such as one of the fixtures or inline text in a test.
+func FromText(text string) *types.DB {
+	return internal.FromReader(io.NopCloser(strings.NewReader(text)))
+}
+
+// FromLines returns the DB parsed from the supplied lines of a pci.ids
+// database file, which saves writing the tabs that indent products,
+// subsystems, subclasses and programming interfaces into a multi-line string:
+//
+//	db := pcidbtest.FromLines(
+//		"8086  Intel Corporation",
+//		"\t10f8  82599 10 Gigabit Dual Port Backplane Connection",
+//	)
+func FromLines(lines ...string) *types.DB {
+	return FromText(strings.Join(lines, "\n"))
+}
+
+// WriteFile writes the supplied pci.ids database file contents to a pci.ids
+// file in a temporary directory that is removed when the test finishes, and
+// returns its path, for use with pcidb.WithPath.
+func WriteFile(t testing.TB, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pci.ids")
+	writeFile(t, path, text)
+	return path
+}
+
+// writeFile writes the supplied text to the file at the supplied path,
+// creating its directory and gzip-compressing the text if the path has a
+// ".gz" suffix, and fails the test on error
+func writeFile(t testing.TB, path string, text string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("pcidbtest: creating directory for %s: %v", path, err)
+	}
+	data := []byte(text)
+	if strings.HasSuffix(path, ".gz") {
+		data = gzipText(t, text)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("pcidbtest: writing %s: %v", path, err)
+	}
+}
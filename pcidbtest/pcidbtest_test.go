@@ -0,0 +1,133 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package pcidbtest_test
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaypipes/pcidb"
+	"github.com/jaypipes/pcidb/pcidbtest"
+	"github.com/jaypipes/pcidb/types"
+)
+
+func TestFixtures(t *testing.T) {
+	basic := pcidbtest.FromText(pcidbtest.Basic)
+	if p := basic.Products["808610f8"]; p == nil || p.Vendor != basic.Vendors["8086"] {
+		t.Fatalf("Expected Basic to have product 808610f8 but got %+v", p)
+	}
+	if basic.Subsystems["101e1960103c60e7"] == nil || basic.ProgrammingInterfaces["0c0010"] == nil {
+		t.Fatalf("Expected Basic to have the NetRAID-1M subsystem and OHCI programming interface")
+	}
+
+	minimal := pcidbtest.FromText(pcidbtest.Minimal)
+	if len(minimal.Vendors) != 1 || len(minimal.Products) != 1 || len(minimal.Subsystems) != 1 ||
+		len(minimal.Classes) != 1 || len(minimal.Subclasses) != 1 {
+		t.Fatalf("Expected a single entry of each kind in Minimal")
+	}
+
+	overlay := pcidbtest.FromText(pcidbtest.Overlay)
+	if overlay.Vendors["8086"].Name != "" || overlay.Products["80860b60"] == nil {
+		t.Fatalf("Expected Overlay to add a product to Intel without naming it")
+	}
+
+	db := pcidbtest.FromLines(
+		"8086  Intel Corporation",
+		"\t10f8  82599 10 Gigabit Dual Port Backplane Connection",
+	)
+	if db.Products["808610f8"] == nil {
+		t.Fatalf("Expected FromLines to parse the product")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := pcidbtest.WriteFile(t, pcidbtest.Minimal)
+	db, err := pcidb.New(pcidb.WithPath(path), pcidb.WithDisableMemoization())
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if len(db.Products) != 1 {
+		t.Fatalf("Expected 1 product but got %d", len(db.Products))
+	}
+}
+
+func TestChroot(t *testing.T) {
+	chroot := pcidbtest.NewChroot(t)
+	if _, err := pcidb.New(chroot.Option()); !errors.Is(err, types.ErrNoDB) {
+		t.Fatalf("Expected ErrNoDB for an empty chroot, but got %v", err)
+	}
+
+	chroot.WriteFile(filepath.Join("usr", "share", "misc", "pci.ids.gz"), pcidbtest.Minimal)
+	db, err := pcidb.New(chroot.Option())
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if len(db.Vendors) != 1 {
+		t.Fatalf("Expected the compressed Minimal fixture, but got %d vendors", len(db.Vendors))
+	}
+
+	path := chroot.WriteHwdata(pcidbtest.Basic)
+	chroot.WriteOverlay("10-site.ids", pcidbtest.Overlay)
+	db, err = pcidb.New(chroot.Option())
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if len(db.Layers) != 2 || db.Layers[0] != path {
+		t.Fatalf("Expected the hwdata file with an overlay, but got layers %q", db.Layers)
+	}
+	if p := db.Products["80860b60"]; p == nil || p.Layer != 1 {
+		t.Fatalf("Expected product from the overlay, but got %+v", p)
+	}
+
+	chroot.WriteCache(pcidbtest.Minimal)
+	db, err = pcidb.New(chroot.Option())
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if db.Products["808610f8"] == nil || len(db.Products) != 3 {
+		t.Fatalf("Expected the cached Minimal fixture with the overlay, but got %d products",
+			len(db.Products))
+	}
+}
+
+func TestMirror(t *testing.T) {
+	mirror := pcidbtest.NewMirror(t, pcidbtest.Minimal)
+	chroot := pcidbtest.NewChroot(t)
+	db, err := pcidb.New(chroot.Option(), mirror.Option())
+	if err != nil {
+		t.Fatalf("Expected no error fetching DB, but got %v", err)
+	}
+	if len(db.Products) != 1 || mirror.Requests() != 1 {
+		t.Fatalf("Expected the Minimal fixture from a single request, but got %d products from %d requests",
+			len(db.Products), mirror.Requests())
+	}
+
+	// Fetching again replaces the cached copy
+	mirror.SetText(t, pcidbtest.Basic)
+	cache := pcidb.NewCache(chroot.Option(), mirror.Option())
+	entry, err := cache.Fetch()
+	if err != nil {
+		t.Fatalf("Expected no error fetching DB, but got %v", err)
+	}
+	if entry.Path != chroot.CachePath() || mirror.Requests() != 2 {
+		t.Fatalf("Expected the fetched DB in the chroot's cache, but got %s", entry.Path)
+	}
+	db, err = pcidb.New(chroot.Option())
+	if err != nil {
+		t.Fatalf("Expected no error loading DB, but got %v", err)
+	}
+	if db.Products["101e1960"] == nil {
+		t.Fatalf("Expected the cached Basic fixture")
+	}
+
+	mirror.SetStatus(http.StatusServiceUnavailable)
+	if _, err := cache.Fetch(); err == nil {
+		t.Fatalf("Expected an error fetching from an unavailable mirror")
+	}
+}
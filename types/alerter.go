@@ -0,0 +1,28 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import (
+	"log"
+	"os"
+)
+
+// Alerter emits warnings about undesirable but non-fatal conditions. The
+// standard library's *log.Logger satisfies this interface.
+type Alerter interface {
+	Printf(format string, v ...interface{})
+}
+
+// DefaultAlerter writes warnings to stderr
+var DefaultAlerter Alerter = log.New(os.Stderr, "", 0)
+
+// NullAlerter discards all warnings
+var NullAlerter Alerter = nullAlerter{}
+
+type nullAlerter struct{}
+
+func (nullAlerter) Printf(format string, v ...interface{}) {}
@@ -0,0 +1,33 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import "time"
+
+// CacheEntry describes a pci.ids database file stored in the pcidb cache
+type CacheEntry struct {
+	// Path is the absolute path to the cached database file
+	Path string `json:"path"`
+	// Version is the value of the "Version:" header comment in the database
+	// file, if any
+	Version string `json:"version"`
+	// Date is the value of the "Date:" header comment in the database file,
+	// if any
+	Date string `json:"date"`
+	// Size is the size in bytes of the cached file on disk
+	Size int64 `json:"size"`
+	// ModTime is the last modification time of the cached file
+	ModTime time.Time `json:"mod_time"`
+	// Compressed is true when the cached file is stored gzip-compressed
+	Compressed bool `json:"compressed"`
+	// Checksum is the hex-encoded SHA-256 digest of the uncompressed
+	// contents of the cached file
+	Checksum string `json:"checksum"`
+	// Versioned is true when the entry is a copy kept in the versioned cache
+	// store rather than the current cache file
+	Versioned bool `json:"versioned"`
+}
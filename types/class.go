@@ -10,8 +10,16 @@ package types
 type Class struct {
 	// ID is the hex-encoded PCI_ID for the device class
 	ID string `json:"id"`
+	// NumericID is the numeric form of ID
+	NumericID ClassID `json:"-"`
 	// Name is the common string name for the class
 	Name string `json:"name"`
+	// Comments are the comment lines directly above the class. See
+	// Vendor.Comments.
+	Comments []string `json:"comments,omitempty"`
+	// Layer is the index in DB.Layers of the pci-ids DB file that the
+	// class's name came from. See Vendor.Layer.
+	Layer int `json:"layer,omitempty"`
 	// Subclasses are any subclasses belonging to this class
 	Subclasses []*Subclass `json:"subclasses"`
 }
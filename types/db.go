@@ -6,12 +6,80 @@
 
 package types
 
+import (
+	"sort"
+	"strings"
+)
+
 type DB struct {
 	// Classes is a map, keyed by class ID, of PCI Class information
 	Classes map[string]*Class `json:"classes"`
 	// Vendors is a map, keyed by vendor ID, of PCI Vendor information
 	Vendors map[string]*Vendor `json:"vendors"`
-	// Products is a map, keyed by vendor ID + product ID, of PCI product
-	// information
+	// Products is a map, keyed by vendor ID + product ID (for example
+	// "808610f8"), of PCI product information
 	Products map[string]*Product `json:"products"`
+	// Subclasses is a map, keyed by class ID + subclass ID (for example
+	// "0108"), of PCI subclass information
+	Subclasses map[string]*Subclass `json:"-"`
+	// ProgrammingInterfaces is a map, keyed by class ID + subclass ID +
+	// programming interface ID (for example "010802"), of PCI programming
+	// interface information
+	ProgrammingInterfaces map[string]*ProgrammingInterface `json:"-"`
+	// Subsystems is a map, keyed by vendor ID + product ID + subvendor ID +
+	// subdevice ID (for example "101e1960103c60e7"), of PCI subsystem
+	// information
+	Subsystems map[string]*Subsystem `json:"-"`
+	// SubvendorSubsystems is a map, keyed by subvendor ID, of the subsystems
+	// of every product that have that subvendor, in the order they appear in
+	// the pci-ids DB file
+	SubvendorSubsystems map[string][]*Subsystem `json:"-"`
+	// Layers are the paths of the pci-ids DB files that the DB was loaded
+	// from when overlays were applied: the base DB file followed by each
+	// overlay, in order of increasing precedence. The Layer field of every
+	// entry is an index into Layers. Layers is nil if no overlays were
+	// applied.
+	Layers []string `json:"layers,omitempty"`
+}
+
+// VendorByID returns the vendor with the supplied vendor ID, or nil if there
+// is no such vendor.
+func (db *DB) VendorByID(id VendorID) *Vendor {
+	return db.Vendors[id.String()]
+}
+
+// ProductByID returns the product with the supplied vendor and device IDs, or
+// nil if there is no such product.
+func (db *DB) ProductByID(vendorID VendorID, deviceID DeviceID) *Product {
+	return db.Products[vendorID.String()+deviceID.String()]
+}
+
+// ClassByID returns the class with the supplied class ID, or nil if there is
+// no such class.
+func (db *DB) ClassByID(id ClassID) *Class {
+	return db.Classes[id.String()]
+}
+
+// SubsystemsBySubvendor returns the subsystems of every product that have the
+// supplied hex-encoded subvendor ID, for example every board that Dell (1028)
+// ships, in the order they appear in the pci-ids DB file. The Product field of
+// each subsystem is the product it is a subsystem of.
+func (db *DB) SubsystemsBySubvendor(id string) []*Subsystem {
+	return db.SubvendorSubsystems[strings.ToLower(id)]
+}
+
+// SubsystemsBySubvendorID returns the subsystems of every product that have
+// the supplied subvendor ID. See SubsystemsBySubvendor.
+func (db *DB) SubsystemsBySubvendorID(id VendorID) []*Subsystem {
+	return db.SubvendorSubsystems[id.String()]
+}
+
+// sortedKeys returns the keys of the supplied map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -9,12 +9,38 @@ package types
 import (
 	"os"
 	"path/filepath"
+	"time"
 )
 
 const (
 	DefaultChroot             = "/"
 	DefaultCacheOnly          = false
 	DefaultEnableNetworkFetch = false
+	DefaultCacheCompress      = false
+	DefaultDisableMemoization = false
+	// DefaultPollInterval is how often a watched pci-ids DB file is checked
+	// for changes
+	DefaultPollInterval = 30 * time.Second
+	// DefaultCacheRetention is the number of versioned copies of pci.ids
+	// database files kept in the cache. Zero keeps every version.
+	DefaultCacheRetention = 0
+	// DefaultSystemCachePath is the location of the shared, system-wide pcidb
+	// cache file used on multi-user hosts
+	DefaultSystemCachePath = "/var/cache/pcidb/pci.ids"
+	// DefaultCacheDirMode is the file mode used when creating cache
+	// directories
+	DefaultCacheDirMode = 0o755
+	// DefaultCacheFileMode is the file mode used when writing cached database
+	// files. Cached files are world-readable so that a shared cache directory
+	// can be populated once and read by every user on the host.
+	DefaultCacheFileMode = 0o644
+	// DefaultOverlayDir is the directory, relative to the chroot, whose
+	// *.ids files are merged on top of the discovered pci-ids DB file as
+	// overlays, in lexical order
+	DefaultOverlayDir = "etc/pci.ids.d"
+	// DefaultFetchURL is the location of the gzip-compressed pci-ids DB file
+	// fetched from the network
+	DefaultFetchURL = "https://pci-ids.ucw.cz/v2.2/pci.ids.gz"
 )
 
 var (
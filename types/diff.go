@@ -0,0 +1,203 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ChangeType is the way an entry differs between two DBs
+type ChangeType int
+
+const (
+	// ChangeAdded is an entry that is only in the new DB
+	ChangeAdded ChangeType = iota
+	// ChangeRemoved is an entry that is only in the old DB
+	ChangeRemoved
+	// ChangeRenamed is an entry that is in both DBs with different names
+	ChangeRenamed
+)
+
+// String returns the name of the change type.
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeRenamed:
+		return "renamed"
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the change type as
+// its name.
+func (t ChangeType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, decoding a change type
+// from its name.
+func (t *ChangeType) UnmarshalText(text []byte) error {
+	for typ := ChangeAdded; typ <= ChangeRenamed; typ++ {
+		if typ.String() == string(text) {
+			*t = typ
+			return nil
+		}
+	}
+	return fmt.Errorf("pcidb: unknown change type %q", text)
+}
+
+// Change is a difference between two DBs in a single entry
+type Change struct {
+	// Type is whether the entry was added, removed or renamed
+	Type ChangeType `json:"type"`
+	// Kind is the kind of entry that changed
+	Kind EntityKind `json:"kind"`
+	// ID is the key of the entry in the DB's flat map for its kind, for
+	// example "808610f8" for a product
+	ID string `json:"id"`
+	// OldName is the name of the entry in the old DB. It is empty for an
+	// added entry.
+	OldName string `json:"old_name,omitempty"`
+	// NewName is the name of the entry in the new DB. It is empty for a
+	// removed entry.
+	NewName string `json:"new_name,omitempty"`
+}
+
+// DBDiff holds the differences between two DBs
+type DBDiff struct {
+	// Changes are the entries that differ. Changes to vendors, products and
+	// subsystems come first, in ID order, so that each vendor's change, if
+	// any, is followed by the changes to its products and theirs by the
+	// changes to their subsystems. Changes to classes, subclasses and
+	// programming interfaces follow in the same way.
+	Changes []Change `json:"changes"`
+}
+
+// Diff returns the differences between the supplied old and new DBs: the
+// vendors, products, subsystems, classes, subclasses and programming
+// interfaces that were added, removed or renamed. When a vendor, product,
+// class or subclass is added or removed, so are each of its children, which
+// are listed as separate changes. A nil DB is treated as an empty one.
+func Diff(oldDB *DB, newDB *DB) *DBDiff {
+	oldNames, newNames := diffNames(oldDB), diffNames(newDB)
+	diff := &DBDiff{Changes: []Change{}}
+	for key, oldName := range oldNames {
+		newName, exists := newNames[key]
+		switch {
+		case !exists:
+			diff.Changes = append(diff.Changes, Change{
+				Type: ChangeRemoved, Kind: key.kind, ID: key.id, OldName: oldName,
+			})
+		case newName != oldName:
+			diff.Changes = append(diff.Changes, Change{
+				Type: ChangeRenamed, Kind: key.kind, ID: key.id,
+				OldName: oldName, NewName: newName,
+			})
+		}
+	}
+	for key, newName := range newNames {
+		if _, exists := oldNames[key]; !exists {
+			diff.Changes = append(diff.Changes, Change{
+				Type: ChangeAdded, Kind: key.kind, ID: key.id, NewName: newName,
+			})
+		}
+	}
+	// IDs are the IDs of the parent entries followed by the entry's own, so
+	// sorting by ID puts children directly after their parents
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if aClass, bClass := a.Kind >= KindClass, b.Kind >= KindClass; aClass != bClass {
+			return bClass
+		}
+		return a.ID < b.ID
+	})
+	return diff
+}
+
+// diffKey identifies an entry of a DB
+type diffKey struct {
+	kind EntityKind
+	id   string
+}
+
+// diffNames returns the names of every entry in the supplied DB. Entries are
+// found by walking the vendors and classes, so that DBs without flat maps
+// can be compared.
+func diffNames(db *DB) map[diffKey]string {
+	names := map[diffKey]string{}
+	if db == nil {
+		return names
+	}
+	for _, v := range db.Vendors {
+		names[diffKey{KindVendor, v.ID}] = v.Name
+		for _, p := range v.Products {
+			names[diffKey{KindProduct, v.ID + p.ID}] = p.Name
+			for _, s := range p.Subsystems {
+				names[diffKey{KindSubsystem, v.ID + p.ID + s.SubvendorID + s.SubdeviceID}] = s.Name
+			}
+		}
+	}
+	for _, c := range db.Classes {
+		names[diffKey{KindClass, c.ID}] = c.Name
+		for _, sc := range c.Subclasses {
+			names[diffKey{KindSubclass, c.ID + sc.ID}] = sc.Name
+			for _, pi := range sc.ProgrammingInterfaces {
+				names[diffKey{KindProgrammingInterface, c.ID + sc.ID + pi.ID}] = pi.Name
+			}
+		}
+	}
+	return names
+}
+
+// Count returns the number of changes of the supplied type.
+func (d *DBDiff) Count(typ ChangeType) int {
+	n := 0
+	for _, c := range d.Changes {
+		if c.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes the changes, one per line, prefixed with "+" for added
+// entries, "-" for removed entries and "~" for renamed entries:
+//
+//	~ vendor 1590: "HPE" -> "Hewlett Packard Enterprise"
+//	+ product 10de2330: GH100 [H100 SXM5 80GB]
+//	- subsystem 808610f88086000c: Ethernet X520 10GbE Dual Port KX4-KR Mezz
+func (d *DBDiff) WriteText(w io.Writer) error {
+	for _, c := range d.Changes {
+		var err error
+		switch c.Type {
+		case ChangeAdded:
+			_, err = fmt.Fprintf(w, "+ %s %s: %s\n", c.Kind, c.ID, c.NewName)
+		case ChangeRemoved:
+			_, err = fmt.Fprintf(w, "- %s %s: %s\n", c.Kind, c.ID, c.OldName)
+		case ChangeRenamed:
+			_, err = fmt.Fprintf(w, "~ %s %s: %q -> %q\n", c.Kind, c.ID, c.OldName, c.NewName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the changes as a JSON document, for example:
+//
+//	{"changes":[{"type":"renamed","kind":"vendor","id":"1590",
+//	"old_name":"HPE","new_name":"Hewlett Packard Enterprise"}]}
+func (d *DBDiff) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(d)
+}
@@ -11,5 +11,11 @@ const (
 	EnvVarPath               = "PCIDB_PATH"
 	EnvVarCacheOnly          = "PCIDB_CACHE_ONLY"
 	EnvVarCachePath          = "PCIDB_CACHE_PATH"
+	EnvVarCacheCompress      = "PCIDB_CACHE_COMPRESS"
+	EnvVarCacheRetention     = "PCIDB_CACHE_RETENTION"
+	EnvVarVersion            = "PCIDB_VERSION"
+	EnvVarExpectedChecksum   = "PCIDB_EXPECTED_CHECKSUM"
 	EnvVarEnableNetworkFetch = "PCIDB_ENABLE_NETWORK_FETCH"
+	EnvVarDisableWarnings    = "PCIDB_DISABLE_WARNINGS"
+	EnvVarFetchURL           = "PCIDB_FETCH_URL"
 )
@@ -15,6 +15,27 @@ var (
 	ErrNoPaths = errors.New(
 		"pcidb: no search paths and cache path is empty.",
 	)
+	ErrInvalidDB = errors.New(
+		"pcidb: file is not a valid pci-ids DB file",
+	)
+	ErrVersionNotFound = errors.New(
+		"pcidb: requested pci-ids DB version not found in cache",
+	)
+	ErrChecksumMismatch = errors.New(
+		"pcidb: pci-ids DB file does not match expected checksum",
+	)
+	ErrInvalidID = errors.New(
+		"pcidb: invalid PCI ID",
+	)
+	ErrDuplicateEntry = errors.New(
+		"pcidb: entry already exists",
+	)
+	ErrMissingParent = errors.New(
+		"pcidb: parent entry does not exist",
+	)
+	ErrEntryNotFound = errors.New(
+		"pcidb: entry does not exist",
+	)
 	// Backwards-compat, deprecated, please reference ErrNoDB
 	ERR_NO_DB = ErrNoDB
 )
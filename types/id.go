@@ -0,0 +1,137 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VendorID is a numeric PCI vendor ID, as read from PCI configuration space
+// or sysfs. Its text form is the zero-padded, lowercase hex string used in
+// the pci-ids DB file, for example "8086".
+type VendorID uint16
+
+// DeviceID is a numeric PCI device (product) ID. Its text form is the
+// zero-padded, lowercase hex string used in the pci-ids DB file, for example
+// "10f8".
+type DeviceID uint16
+
+// ClassID is a numeric PCI device class ID. Its text form is the zero-padded,
+// lowercase hex string used in the pci-ids DB file, for example "02".
+type ClassID uint8
+
+// ParseVendorID parses a hex-encoded PCI vendor ID such as "8086", "8086\n"
+// or "0x8086", the form found in sysfs.
+func ParseVendorID(s string) (VendorID, error) {
+	id, err := parseID(s, 16)
+	return VendorID(id), err
+}
+
+// ParseDeviceID parses a hex-encoded PCI device ID such as "10f8" or
+// "0x10f8".
+func ParseDeviceID(s string) (DeviceID, error) {
+	id, err := parseID(s, 16)
+	return DeviceID(id), err
+}
+
+// ParseClassID parses a hex-encoded PCI class ID such as "02" or "0x02".
+func ParseClassID(s string) (ClassID, error) {
+	id, err := parseID(s, 8)
+	return ClassID(id), err
+}
+
+// String returns the vendor ID as a 4-digit lowercase hex string.
+func (id VendorID) String() string {
+	return formatID(uint64(id), 4)
+}
+
+// String returns the device ID as a 4-digit lowercase hex string.
+func (id DeviceID) String() string {
+	return formatID(uint64(id), 4)
+}
+
+// String returns the class ID as a 2-digit lowercase hex string.
+func (id ClassID) String() string {
+	return formatID(uint64(id), 2)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id VendorID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id DeviceID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id ClassID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *VendorID) UnmarshalText(text []byte) error {
+	parsed, err := ParseVendorID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *DeviceID) UnmarshalText(text []byte) error {
+	parsed, err := ParseDeviceID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *ClassID) UnmarshalText(text []byte) error {
+	parsed, err := ParseClassID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// parseID parses a hex-encoded ID of the supplied bit size, ignoring
+// surrounding whitespace and an optional "0x" prefix
+func parseID(s string, bitSize int) (uint64, error) {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) > 2 && trimmed[0] == '0' &&
+		(trimmed[1] == 'x' || trimmed[1] == 'X') {
+		trimmed = trimmed[2:]
+	}
+	if trimmed == "" || len(trimmed) > bitSize/4 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	id, err := strconv.ParseUint(trimmed, 16, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	return id, nil
+}
+
+// formatID formats an ID as a zero-padded, lowercase hex string of the
+// supplied width
+func formatID(id uint64, width int) string {
+	const digits = "0123456789abcdef"
+	var b [4]byte
+	for x := width - 1; x >= 0; x-- {
+		b[x] = digits[id&0xf]
+		id >>= 4
+	}
+	return string(b[:width])
+}
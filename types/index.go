@@ -0,0 +1,439 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Index is an in-memory index of the names of the vendors and products in a
+// DB, for ranked, typo-tolerant, as-you-type suggestions. Build one with
+// NewIndex. An Index is safe for concurrent use and does not change if the DB
+// it was built from does.
+//
+// Names are split into lowercase words, as with SearchTokens. The index holds
+// the sorted set of every word, which answers prefix queries in the same way
+// as a trie would, the vendors and products each word appears in, and the
+// trigrams of each word for fuzzy matching.
+type Index struct {
+	entries    []indexEntry
+	entryWords [][]int32          // words in each entry, unique
+	words      []string           // sorted, unique
+	postings   [][]int32          // entries each word appears in, ascending
+	trigrams   map[string][]int32 // words each trigram appears in, ascending
+	scratch    sync.Pool          // *indexScratch
+}
+
+// indexEntry is a vendor or product in an Index
+type indexEntry struct {
+	kind    EntityKind
+	id      string
+	name    string
+	nwords  int
+	vendor  *Vendor
+	product *Product
+}
+
+// indexScratch holds the per-query state of Index.Suggest, which is reused
+// across queries. Every element of each slice is zero between queries.
+type indexScratch struct {
+	entryScores []float64 // sum of query word scores, or -1 once rejected
+	wordScores  []float64 // score of each word for the current query word
+	shared      []int32   // trigrams shared with the current query word
+}
+
+// Suggestion is a vendor or product whose name matched a query passed to
+// Index.Suggest. Spans are the whole words of Name that matched.
+type Suggestion struct {
+	SearchHit
+	// Score ranks the suggestion, higher is better. Scores are between 0 and
+	// 1, and 1 means every word of the query and of the name matched
+	// exactly.
+	Score float64 `json:"score"`
+}
+
+// Scores for a word of a name that matches a word of a query exactly, that
+// starts with the last, possibly incomplete, word of a query, or that is a
+// small number of edits from a word of a query
+const (
+	scoreExact  = 1.0
+	scorePrefix = 0.8
+	scoreFuzzy  = 0.5
+)
+
+// NewIndex returns an Index of the names of the vendors and products in the
+// supplied DB.
+func NewIndex(db *DB) *Index {
+	idx := &Index{trigrams: map[string][]int32{}}
+	ids := map[string]int32{}
+	var words []string
+	var postings [][]int32
+	add := func(e indexEntry) {
+		x := int32(len(idx.entries))
+		lower := lowerASCII(e.name)
+		var entryWords []int32
+		for _, span := range tokenSpans(lower) {
+			word := lower[span[0]:span[1]]
+			e.nwords++
+			id, exists := ids[word]
+			if !exists {
+				id = int32(len(words))
+				ids[word] = id
+				words = append(words, word)
+				postings = append(postings, nil)
+			}
+			if p := postings[id]; len(p) == 0 || p[len(p)-1] != x {
+				postings[id] = append(p, x)
+				entryWords = append(entryWords, id)
+			}
+		}
+		idx.entries = append(idx.entries, e)
+		idx.entryWords = append(idx.entryWords, entryWords)
+	}
+	for _, vid := range sortedKeys(db.Vendors) {
+		v := db.Vendors[vid]
+		add(indexEntry{kind: KindVendor, id: v.ID, name: v.Name, vendor: v})
+		for _, p := range v.Products {
+			add(indexEntry{
+				kind: KindProduct, id: v.ID + p.ID, name: p.Name,
+				vendor: v, product: p,
+			})
+		}
+	}
+
+	// Renumber the words in sorted order
+	order := make([]int32, len(words))
+	for x := range order {
+		order[x] = int32(x)
+	}
+	sort.Slice(order, func(i, j int) bool { return words[order[i]] < words[order[j]] })
+	renumber := make([]int32, len(words))
+	idx.words = make([]string, len(words))
+	idx.postings = make([][]int32, len(words))
+	for x, old := range order {
+		renumber[old] = int32(x)
+		idx.words[x] = words[old]
+		idx.postings[x] = postings[old]
+		for _, tri := range trigramsOf(words[old]) {
+			idx.trigrams[tri] = append(idx.trigrams[tri], int32(x))
+		}
+	}
+	for _, entryWords := range idx.entryWords {
+		for x, old := range entryWords {
+			entryWords[x] = renumber[old]
+		}
+	}
+	idx.scratch.New = func() any {
+		return &indexScratch{
+			entryScores: make([]float64, len(idx.entries)),
+			wordScores:  make([]float64, len(idx.words)),
+			shared:      make([]int32, len(idx.words)),
+		}
+	}
+	return idx
+}
+
+// wordMatch is a word of the index that matches a word of a query
+type wordMatch struct {
+	word  int32
+	score float64
+}
+
+// Suggest returns up to limit vendors and products whose names contain every
+// word of the supplied query, best first. Zero or a negative limit returns
+// every match.
+//
+// A word of the query matches a word of a name if they are the same, if it
+// is a few typos away from it (one for words of up to five letters, two for
+// longer words), or, for the last word of the query, if the name's word
+// starts with it, so that suggestions can be made as the query is typed.
+// Suggestions are ranked by how well each word of the query matched and by
+// how much of the name the query covers.
+func (idx *Index) Suggest(query string, limit int) []Suggestion {
+	q := lowerASCII(query)
+	spans := tokenSpans(q)
+	if len(spans) == 0 || len(idx.entries) == 0 {
+		return nil
+	}
+	// The last word is only a prefix if it is still being typed
+	partial := spans[len(spans)-1][1] == len(q)
+	scratch := idx.scratch.Get().(*indexScratch)
+	defer idx.scratch.Put(scratch)
+
+	type queryWord struct {
+		matches  []wordMatch
+		postings int
+	}
+	qwords := make([]queryWord, len(spans))
+	for n, span := range spans {
+		matches := idx.match(scratch, q[span[0]:span[1]], partial && n == len(spans)-1)
+		if len(matches) == 0 {
+			return nil
+		}
+		qwords[n].matches = matches
+		for _, m := range matches {
+			qwords[n].postings += len(idx.postings[m.word])
+		}
+	}
+	// Start with the most selective query word, so that the other words only
+	// need to be checked against the entries it matched
+	sort.Slice(qwords, func(i, j int) bool { return qwords[i].postings < qwords[j].postings })
+
+	var candidates []int32
+	scores := scratch.entryScores
+	for _, m := range qwords[0].matches {
+		for _, e := range idx.postings[m.word] {
+			if scores[e] == 0 {
+				candidates = append(candidates, e)
+			}
+			scores[e] = max(scores[e], m.score)
+		}
+	}
+	for _, qw := range qwords[1:] {
+		for _, m := range qw.matches {
+			scratch.wordScores[m.word] = max(scratch.wordScores[m.word], m.score)
+		}
+		for _, e := range candidates {
+			if scores[e] < 0 {
+				continue
+			}
+			best := 0.0
+			for _, w := range idx.entryWords[e] {
+				best = max(best, scratch.wordScores[w])
+			}
+			if best == 0 {
+				scores[e] = -1
+			} else {
+				scores[e] += best
+			}
+		}
+		for _, m := range qw.matches {
+			scratch.wordScores[m.word] = 0
+		}
+	}
+
+	ranked := &rankedEntries{idx: idx}
+	for _, e := range candidates {
+		if scores[e] > 0 {
+			coverage := min(float64(len(spans))/float64(idx.entries[e].nwords), 1)
+			ranked.push(e, scores[e]/float64(len(spans))*(0.9+0.1*coverage), limit)
+		}
+		scores[e] = 0
+	}
+	sort.Sort(sort.Reverse(ranked))
+
+	suggestions := make([]Suggestion, len(ranked.entries))
+	for x, e := range ranked.entries {
+		entry := &idx.entries[e]
+		suggestions[x] = Suggestion{
+			SearchHit: SearchHit{
+				Kind:    entry.kind,
+				ID:      entry.id,
+				Name:    entry.name,
+				Spans:   matchedSpans(entry.name, q, spans, partial),
+				Vendor:  entry.vendor,
+				Product: entry.product,
+			},
+			Score: ranked.scores[x],
+		}
+	}
+	return suggestions
+}
+
+// match returns the words of the index that match the supplied query word,
+// and their scores
+func (idx *Index) match(scratch *indexScratch, word string, partial bool) []wordMatch {
+	var matches []wordMatch
+	first := sort.SearchStrings(idx.words, word)
+	for x := first; x < len(idx.words) && strings.HasPrefix(idx.words[x], word); x++ {
+		switch {
+		case idx.words[x] == word:
+			matches = append(matches, wordMatch{int32(x), scoreExact})
+		case partial:
+			// Longer completions of the word score lower, but never as low
+			// as a fuzzy match
+			ratio := float64(len(word)) / float64(len(idx.words[x]))
+			matches = append(matches, wordMatch{int32(x), scorePrefix + 0.1*ratio})
+		}
+	}
+	if len(word) < 3 {
+		return matches
+	}
+	maxDist := fuzzyDistance(word)
+	// An edit changes at most three of a word's trigrams, so candidates must
+	// share all but 3*maxDist of them. When the word is a prefix, its last,
+	// space-padded trigram isn't in longer words either.
+	tris := trigramsOf(word)
+	need := len(tris) - 3*maxDist
+	if partial {
+		need--
+	}
+	var touched []int32
+	for _, tri := range tris {
+		for _, x := range idx.trigrams[tri] {
+			if scratch.shared[x] == 0 {
+				touched = append(touched, x)
+			}
+			scratch.shared[x]++
+		}
+	}
+	for _, x := range touched {
+		n := int(scratch.shared[x])
+		scratch.shared[x] = 0
+		candidate := idx.words[x]
+		if n < need || strings.HasPrefix(candidate, word) {
+			continue
+		}
+		if dist := fuzzyMatch(word, candidate, partial, maxDist); dist <= maxDist {
+			matches = append(matches, wordMatch{x, scoreFuzzy - 0.15*float64(dist-1)})
+		}
+	}
+	return matches
+}
+
+// fuzzyDistance returns the number of typos tolerated in the supplied query
+// word
+func fuzzyDistance(word string) int {
+	if len(word) > 5 {
+		return 2
+	}
+	return 1
+}
+
+// fuzzyMatch returns the edit distance between the supplied query word and
+// a word of a name, or between the query word and the start of the name's
+// word if the query word is partial, or maxDist+1 if it is greater than
+// maxDist
+func fuzzyMatch(word string, candidate string, partial bool, maxDist int) int {
+	dist := editDistance(word, candidate, maxDist)
+	if partial && len(candidate) > len(word) {
+		dist = min(dist, editDistance(word, candidate[:len(word)], maxDist))
+	}
+	return dist
+}
+
+// matchedSpans returns the spans of the words of the supplied name that match
+// a word of the supplied lowercase query
+func matchedSpans(name string, q string, qspans [][2]int, partial bool) [][2]int {
+	lower := lowerASCII(name)
+	var spans [][2]int
+	for _, span := range tokenSpans(lower) {
+		word := lower[span[0]:span[1]]
+		for n, qspan := range qspans {
+			qword := q[qspan[0]:qspan[1]]
+			last := partial && n == len(qspans)-1
+			if word == qword || last && strings.HasPrefix(word, qword) ||
+				len(qword) >= 3 && fuzzyMatch(qword, word, last, fuzzyDistance(qword)) <= fuzzyDistance(qword) {
+				spans = append(spans, span)
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// rankedEntries holds the best-ranked entries found so far, as a min-heap
+type rankedEntries struct {
+	idx     *Index
+	entries []int32
+	scores  []float64
+}
+
+// push adds the supplied entry, keeping at most limit entries if limit is
+// positive
+func (r *rankedEntries) push(e int32, score float64, limit int) {
+	if limit <= 0 || len(r.entries) < limit {
+		heap.Push(r, wordMatch{e, score})
+		return
+	}
+	if r.better(e, score, r.entries[0], r.scores[0]) {
+		r.entries[0], r.scores[0] = e, score
+		heap.Fix(r, 0)
+	}
+}
+
+// better returns whether entry a ranks above entry b
+func (r *rankedEntries) better(a int32, aScore float64, b int32, bScore float64) bool {
+	ea, eb := &r.idx.entries[a], &r.idx.entries[b]
+	switch {
+	case aScore != bScore:
+		return aScore > bScore
+	case ea.kind != eb.kind:
+		return ea.kind < eb.kind
+	case len(ea.name) != len(eb.name):
+		return len(ea.name) < len(eb.name)
+	}
+	return ea.id < eb.id
+}
+
+func (r *rankedEntries) Len() int { return len(r.entries) }
+
+func (r *rankedEntries) Less(i, j int) bool {
+	return r.better(r.entries[j], r.scores[j], r.entries[i], r.scores[i])
+}
+
+func (r *rankedEntries) Swap(i, j int) {
+	r.entries[i], r.entries[j] = r.entries[j], r.entries[i]
+	r.scores[i], r.scores[j] = r.scores[j], r.scores[i]
+}
+
+func (r *rankedEntries) Push(x any) {
+	m := x.(wordMatch)
+	r.entries = append(r.entries, m.word)
+	r.scores = append(r.scores, m.score)
+}
+
+func (r *rankedEntries) Pop() any {
+	n := len(r.entries) - 1
+	m := wordMatch{r.entries[n], r.scores[n]}
+	r.entries, r.scores = r.entries[:n], r.scores[:n]
+	return m
+}
+
+// trigramsOf returns the trigrams of the supplied word, padded with a leading
+// and trailing space so that every letter is part of three trigrams
+func trigramsOf(word string) []string {
+	padded := " " + word + " "
+	tris := make([]string, 0, len(padded)-2)
+	for x := 0; x+3 <= len(padded); x++ {
+		tris = append(tris, padded[x:x+3])
+	}
+	return tris
+}
+
+// editDistance returns the Levenshtein distance between the supplied strings,
+// or maxDist+1 if it is greater than maxDist
+func editDistance(a string, b string, maxDist int) int {
+	if d := len(a) - len(b); d > maxDist || -d > maxDist {
+		return maxDist + 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > maxDist {
+			return maxDist + 1
+		}
+		prev, cur = cur, prev
+	}
+	return min(prev[len(b)], maxDist+1)
+}
@@ -0,0 +1,78 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import "iter"
+
+// AllVendors returns an iterator over the vendors in the DB that match every
+// one of the supplied filters, in vendor ID order.
+func (db *DB) AllVendors(filters ...func(*Vendor) bool) iter.Seq[*Vendor] {
+	return func(yield func(*Vendor) bool) {
+		for _, id := range sortedKeys(db.Vendors) {
+			v := db.Vendors[id]
+			if matches(v, filters) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// AllProducts returns an iterator over the products in the DB that match
+// every one of the supplied filters, in vendor ID and then product ID order.
+func (db *DB) AllProducts(filters ...func(*Product) bool) iter.Seq[*Product] {
+	return func(yield func(*Product) bool) {
+		for _, key := range sortedKeys(db.Products) {
+			p := db.Products[key]
+			if matches(p, filters) && !yield(p) {
+				return
+			}
+		}
+	}
+}
+
+// AllSubsystems returns an iterator over the products in the DB and each of
+// their subsystems that match every one of the supplied filters, in vendor ID
+// and then product ID order. The subsystems of a product are in the order
+// they appear in the pci-ids DB file.
+func (db *DB) AllSubsystems(
+	filters ...func(*Subsystem) bool,
+) iter.Seq2[*Product, *Subsystem] {
+	return func(yield func(*Product, *Subsystem) bool) {
+		for _, key := range sortedKeys(db.Products) {
+			p := db.Products[key]
+			for _, s := range p.Subsystems {
+				if matches(s, filters) && !yield(p, s) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// AllClasses returns an iterator over the classes in the DB that match every
+// one of the supplied filters, in class ID order.
+func (db *DB) AllClasses(filters ...func(*Class) bool) iter.Seq[*Class] {
+	return func(yield func(*Class) bool) {
+		for _, id := range sortedKeys(db.Classes) {
+			c := db.Classes[id]
+			if matches(c, filters) && !yield(c) {
+				return
+			}
+		}
+	}
+}
+
+// matches returns whether the supplied entry matches every one of the
+// supplied filters
+func matches[T any](entry T, filters []func(T) bool) bool {
+	for _, filter := range filters {
+		if !filter(entry) {
+			return false
+		}
+	}
+	return true
+}
@@ -6,6 +6,8 @@
 
 package types
 
+import "time"
+
 var (
 	trueVar = true
 )
@@ -22,12 +24,50 @@ type WithOption struct {
 	// CachePath overrides the pcidb cache path, which defaults to
 	// $HOME/.cache/pci.ids
 	CachePath *string
+	// CacheCompress stores pci-ids DB files fetched from the network
+	// gzip-compressed in the pcidb cache
+	CacheCompress *bool
+	// CacheRetention is the number of versioned copies of pci-ids DB files
+	// kept in the pcidb cache. Zero keeps every version.
+	CacheRetention *int
+	// Version selects a specific pci-ids DB version (the value of the
+	// "Version:" header comment) from the versioned cache store
+	Version *string
+	// ExpectedChecksum is the hex-encoded SHA-256 digest that the
+	// uncompressed contents of the pci-ids DB file must match
+	ExpectedChecksum *string
 	// Enables fetching a pci-ids from a known location on the network if no
 	// local pci-ids DB files can be found.
 	EnableNetworkFetch *bool
+	// FetchURL overrides the location that the gzip-compressed pci-ids DB
+	// file is fetched from, which defaults to DefaultFetchURL
+	FetchURL *string
 	// Path points to the absolute path of a pci.ids file in a non-standard
 	// location.
 	Path *string
+	// DisableMemoization forces a fresh parse of the pci-ids DB file instead
+	// of returning a previously-parsed DB shared with other callers
+	DisableMemoization *bool
+	// PollInterval is how often a watched pci-ids DB file is checked for
+	// changes
+	PollInterval *time.Duration
+	// Alerter receives warnings about non-fatal conditions, such as being
+	// unable to write a network-fetched pci-ids DB file to the cache
+	Alerter Alerter
+	// Vendors restricts the vendors, and their products, that are loaded to
+	// those with the listed hex-encoded IDs. Nil loads every vendor.
+	Vendors []string
+	// Classes restricts the classes that are loaded to those with the listed
+	// hex-encoded IDs. Nil loads every class.
+	Classes []string
+	// SkipSubsystems skips loading the subsystems of every product
+	SkipSubsystems *bool
+	// Comments loads the comment lines directly above each entry into its
+	// Comments field
+	Comments *bool
+	// Overlays are the paths of pci-ids DB files whose entries are merged
+	// on top of the DB, in order of increasing precedence
+	Overlays []string
 }
 
 // WithChroot overrides the root directory used for discovery of pci-ids
@@ -42,6 +82,38 @@ func WithCachePath(path string) *WithOption {
 	return &WithOption{CachePath: &path}
 }
 
+// WithSystemCache points pcidb at the shared, system-wide cache file
+// (/var/cache/pcidb/pci.ids) instead of the per-user cache file.
+func WithSystemCache() *WithOption {
+	path := DefaultSystemCachePath
+	return &WithOption{CachePath: &path}
+}
+
+// WithCacheCompress stores pci.ids database files fetched from the network
+// gzip-compressed in the pcidb cache.
+func WithCacheCompress() *WithOption {
+	return &WithOption{CacheCompress: &trueVar}
+}
+
+// WithCacheRetention sets the number of versioned copies of pci.ids database
+// files that are kept in the pcidb cache. Zero keeps every version.
+func WithCacheRetention(n int) *WithOption {
+	return &WithOption{CacheRetention: &n}
+}
+
+// WithVersion loads a specific, previously-cached version of the pci.ids
+// database (for example "2025.08.20") from the versioned cache store instead
+// of discovering the current one.
+func WithVersion(version string) *WithOption {
+	return &WithOption{Version: &version}
+}
+
+// WithExpectedChecksum requires that the uncompressed contents of the pci.ids
+// database file match the supplied hex-encoded SHA-256 digest.
+func WithExpectedChecksum(checksum string) *WithOption {
+	return &WithOption{ExpectedChecksum: &checksum}
+}
+
 // WithCacheOnly disables lookup of pci.ids database files over the network and
 // forces pcidb to only use any pre-cached pci.ids database files in its cache
 // directory.
@@ -64,3 +136,69 @@ var WithDirectPath = WithPath
 func WithEnableNetworkFetch() *WithOption {
 	return &WithOption{EnableNetworkFetch: &trueVar}
 }
+
+// WithFetchURL fetches the gzip-compressed pci-ids DB file from the supplied
+// URL, such as an internal mirror, instead of from pci-ids.ucw.cz when network
+// fetching is enabled.
+func WithFetchURL(url string) *WithOption {
+	return &WithOption{FetchURL: &url}
+}
+
+// WithDisableMemoization forces pcidb to parse the pci.ids database file
+// again instead of returning a DB shared with other callers. Use this if you
+// need to modify the returned DB.
+func WithDisableMemoization() *WithOption {
+	return &WithOption{DisableMemoization: &trueVar}
+}
+
+// WithPollInterval sets how often a watched pci.ids database file is checked
+// for changes.
+func WithPollInterval(interval time.Duration) *WithOption {
+	return &WithOption{PollInterval: &interval}
+}
+
+// WithAlerter sends warnings about non-fatal conditions to the supplied
+// Alerter instead of stderr.
+func WithAlerter(alerter Alerter) *WithOption {
+	return &WithOption{Alerter: alerter}
+}
+
+// WithDisableWarnings silences warnings about non-fatal conditions.
+func WithDisableWarnings() *WithOption {
+	return &WithOption{Alerter: NullAlerter}
+}
+
+// WithVendors only loads the vendors, and their products, with the supplied
+// hex-encoded vendor IDs, for example WithVendors("8086", "10de").
+func WithVendors(ids ...string) *WithOption {
+	return &WithOption{Vendors: append([]string{}, ids...)}
+}
+
+// WithClasses only loads the classes with the supplied hex-encoded class IDs,
+// for example WithClasses("02", "0c").
+func WithClasses(ids ...string) *WithOption {
+	return &WithOption{Classes: append([]string{}, ids...)}
+}
+
+// WithoutSubsystems skips loading the subsystems of every product, which make
+// up most of the pci.ids database.
+func WithoutSubsystems() *WithOption {
+	return &WithOption{SkipSubsystems: &trueVar}
+}
+
+// WithComments loads the comments attached to vendors, products, subsystems
+// and classes in the pci-ids DB file into their Comments fields. Comments are
+// not kept in the compiled form of the cached pci-ids DB file, so the text
+// file is always parsed.
+func WithComments() *WithOption {
+	return &WithOption{Comments: &trueVar}
+}
+
+// WithOverlay merges the entries of the pci-ids DB file at the supplied path,
+// such as a site-local fragment listing pre-release or internal devices, on
+// top of the DB. Unlike other options, overlays accumulate: each WithOverlay
+// adds a layer that takes precedence over the base DB, the files in the
+// pci.ids.d overlay directory and any overlays added before it.
+func WithOverlay(path string) *WithOption {
+	return &WithOption{Overlays: []string{path}}
+}
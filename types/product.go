@@ -13,10 +13,23 @@ package types
 type Product struct {
 	// VendorID is the vendor ID for the product
 	VendorID string `json:"vendor_id"`
+	// NumericVendorID is the numeric form of VendorID
+	NumericVendorID VendorID `json:"-"`
+	// Vendor is the vendor of the product. It is not included in JSON
+	// output, which would otherwise be cyclic.
+	Vendor *Vendor `json:"-"`
 	// ID is the hex-encoded PCI_ID for the product/model
 	ID string `json:"id"`
+	// NumericID is the numeric form of ID
+	NumericID DeviceID `json:"-"`
 	// Name is the common string name of the vendor
 	Name string `json:"name"`
+	// Comments are the comment lines directly above the product. See
+	// Vendor.Comments.
+	Comments []string `json:"comments,omitempty"`
+	// Layer is the index in DB.Layers of the pci-ids DB file that the
+	// product's name came from. See Vendor.Layer.
+	Layer int `json:"layer,omitempty"`
 	// Subsystems contains "subdevices" or "subsystems" for the product
-	Subsystems []*Product `json:"subsystems"`
+	Subsystems []*Subsystem `json:"subsystems"`
 }
@@ -13,4 +13,13 @@ type ProgrammingInterface struct {
 	ID string `json:"id"`
 	// Name is the common string name for the programming interface
 	Name string `json:"name"`
+	// Comments are the comment lines directly above the programming
+	// interface. See Vendor.Comments.
+	Comments []string `json:"comments,omitempty"`
+	// Layer is the index in DB.Layers of the pci-ids DB file that the
+	// programming interface's name came from. See Vendor.Layer.
+	Layer int `json:"layer,omitempty"`
+	// Subclass is the subclass the programming interface belongs to. It is
+	// not included in JSON output, which would otherwise be cyclic.
+	Subclass *Subclass `json:"-"`
 }
@@ -0,0 +1,344 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EntityKind is a kind of entry in the DB
+type EntityKind int
+
+const (
+	KindVendor EntityKind = iota
+	KindProduct
+	KindSubsystem
+	KindClass
+	KindSubclass
+	KindProgrammingInterface
+)
+
+// String returns the name of the entity kind.
+func (k EntityKind) String() string {
+	switch k {
+	case KindVendor:
+		return "vendor"
+	case KindProduct:
+		return "product"
+	case KindSubsystem:
+		return "subsystem"
+	case KindClass:
+		return "class"
+	case KindSubclass:
+		return "subclass"
+	case KindProgrammingInterface:
+		return "programming interface"
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the entity kind as
+// its name.
+func (k EntityKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, decoding an entity kind
+// from its name.
+func (k *EntityKind) UnmarshalText(text []byte) error {
+	for kind := KindVendor; kind <= KindProgrammingInterface; kind++ {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("pcidb: unknown entity kind %q", text)
+}
+
+// SearchMode is how a search query is matched against the names of entries
+type SearchMode int
+
+const (
+	// SearchSubstring matches names that contain the query
+	SearchSubstring SearchMode = iota
+	// SearchRegexp matches names that match the query as a regular
+	// expression (see the regexp package). Empty matches are ignored, so
+	// that, as with an empty SearchSubstring query, a pattern such as "" or
+	// "^" matches no names, and "x*" only matches names containing an x.
+	SearchRegexp
+	// SearchTokens matches names that contain every word of the query as a
+	// whole word, in any order. Words are runs of letters and digits, so the
+	// queries "connectx 6" and "6 ConnectX" both match "[ConnectX-6 Dx]" but
+	// not "ConnectX-6DX".
+	SearchTokens
+)
+
+// SearchOptions modifies how DB.Search matches entries. The zero value
+// matches the query as a substring of the names of entries of every kind.
+type SearchOptions struct {
+	// Mode is how the query is matched against names
+	Mode SearchMode
+	// Kinds restricts the hits to entries of the listed kinds. Nil allows
+	// every kind.
+	Kinds []EntityKind
+	// VendorID restricts the hits to the vendor with the supplied
+	// hex-encoded ID, its products and their subsystems
+	VendorID string
+	// ClassID restricts the hits to the class with the supplied hex-encoded
+	// ID, its subclasses and their programming interfaces
+	ClassID string
+	// Limit is the maximum number of hits returned. Zero returns every hit.
+	Limit int
+}
+
+// SearchHit is an entry whose name matched a search query
+type SearchHit struct {
+	// Kind is the kind of entry that matched
+	Kind EntityKind `json:"kind"`
+	// ID is the key of the entry in the DB's flat map for its kind, for
+	// example "808610f8" for a product
+	ID string `json:"id"`
+	// Name is the name of the entry
+	Name string `json:"name"`
+	// Spans are the [start, end) byte offsets of the matched parts of Name
+	Spans [][2]int `json:"spans"`
+
+	// The entry that matched, and its parents. Only those for the entry's
+	// kind are set: for example, a KindSubsystem hit has Vendor, Product and
+	// Subsystem set.
+	Vendor               *Vendor               `json:"-"`
+	Product              *Product              `json:"-"`
+	Subsystem            *Subsystem            `json:"-"`
+	Class                *Class                `json:"-"`
+	Subclass             *Subclass             `json:"-"`
+	ProgrammingInterface *ProgrammingInterface `json:"-"`
+}
+
+// Search returns the entries whose names match the supplied query,
+// ignoring case. Every mode folds case the same way, using Unicode simple
+// case folding as strings.EqualFold and the (?i) flag of regular
+// expressions do. Vendors, with their products and subsystems, are searched
+// in vendor ID order, followed by classes in class ID order. Search only
+// returns an error if the query is an invalid regular expression.
+func (db *DB) Search(query string, opts *SearchOptions) ([]SearchHit, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	match, err := matcher(query, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	kinds := map[EntityKind]bool{}
+	for _, k := range opts.Kinds {
+		kinds[k] = true
+	}
+	vendorID := strings.ToLower(opts.VendorID)
+	classID := strings.ToLower(opts.ClassID)
+	// Scoping the search to a vendor excludes classes unless they are also
+	// scoped, and the other way around
+	searchVendors := vendorID != "" || classID == ""
+	searchClasses := classID != "" || vendorID == ""
+
+	var hits []SearchHit
+	full := func() bool { return opts.Limit > 0 && len(hits) >= opts.Limit }
+	try := func(hit SearchHit) {
+		if full() || (len(kinds) > 0 && !kinds[hit.Kind]) {
+			return
+		}
+		if hit.Spans = match(hit.Name); hit.Spans != nil {
+			hits = append(hits, hit)
+		}
+	}
+	if searchVendors {
+		for _, vid := range sortedKeys(db.Vendors) {
+			if vendorID != "" && vid != vendorID {
+				continue
+			}
+			v := db.Vendors[vid]
+			try(SearchHit{Kind: KindVendor, ID: v.ID, Name: v.Name, Vendor: v})
+			for _, p := range v.Products {
+				try(SearchHit{
+					Kind: KindProduct, ID: v.ID + p.ID, Name: p.Name,
+					Vendor: v, Product: p,
+				})
+				for _, s := range p.Subsystems {
+					try(SearchHit{
+						Kind: KindSubsystem,
+						ID:   v.ID + p.ID + s.SubvendorID + s.SubdeviceID,
+						Name: s.Name, Vendor: v, Product: p, Subsystem: s,
+					})
+				}
+			}
+		}
+	}
+	if searchClasses {
+		for _, cid := range sortedKeys(db.Classes) {
+			if classID != "" && cid != classID {
+				continue
+			}
+			c := db.Classes[cid]
+			try(SearchHit{Kind: KindClass, ID: c.ID, Name: c.Name, Class: c})
+			for _, sc := range c.Subclasses {
+				try(SearchHit{
+					Kind: KindSubclass, ID: c.ID + sc.ID, Name: sc.Name,
+					Class: c, Subclass: sc,
+				})
+				for _, pi := range sc.ProgrammingInterfaces {
+					try(SearchHit{
+						Kind: KindProgrammingInterface,
+						ID:   c.ID + sc.ID + pi.ID, Name: pi.Name,
+						Class: c, Subclass: sc, ProgrammingInterface: pi,
+					})
+				}
+			}
+		}
+	}
+	return hits, nil
+}
+
+// matcher returns a function that returns the spans of the supplied name
+// that match the supplied query, or nil if the name doesn't match
+func matcher(query string, mode SearchMode) (func(string) [][2]int, error) {
+	switch mode {
+	case SearchRegexp:
+		re, err := regexp.Compile("(?i)" + query)
+		if err != nil {
+			return nil, err
+		}
+		return func(name string) [][2]int {
+			var spans [][2]int
+			for _, loc := range re.FindAllStringIndex(name, -1) {
+				if loc[0] < loc[1] {
+					spans = append(spans, [2]int{loc[0], loc[1]})
+				}
+			}
+			return spans
+		}, nil
+	case SearchTokens:
+		var words []string
+		for _, tok := range tokenSpans(query) {
+			words = append(words, query[tok[0]:tok[1]])
+		}
+		return func(name string) [][2]int {
+			if len(words) == 0 {
+				return nil
+			}
+			toks := tokenSpans(name)
+			var spans [][2]int
+			for _, word := range words {
+				found := false
+				for _, tok := range toks {
+					if strings.EqualFold(name[tok[0]:tok[1]], word) {
+						spans = append(spans, tok)
+						found = true
+					}
+				}
+				if !found {
+					return nil
+				}
+			}
+			return spans
+		}, nil
+	}
+	return func(name string) [][2]int {
+		var spans [][2]int
+		for start := 0; query != "" && start < len(name); {
+			if n := prefixFold(name[start:], query); n > 0 {
+				spans = append(spans, [2]int{start, start + n})
+				start += n
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(name[start:])
+			start += size
+		}
+		return spans
+	}, nil
+}
+
+// prefixFold returns the length of the prefix of s that equals the supplied
+// prefix under Unicode simple case folding, as with strings.EqualFold and
+// the (?i) flag of regular expressions, or -1 if s has no such prefix. The
+// prefix may be a different length in s, for example when s contains the
+// Kelvin sign where the prefix has a k.
+func prefixFold(s string, prefix string) int {
+	n := 0
+	for _, pr := range prefix {
+		if n >= len(s) {
+			return -1
+		}
+		sr, size := utf8.DecodeRuneInString(s[n:])
+		if !equalFoldRune(sr, pr) {
+			return -1
+		}
+		n += size
+	}
+	return n
+}
+
+// equalFoldRune reports whether the supplied runes are equal under Unicode
+// simple case folding
+func equalFoldRune(a rune, b rune) bool {
+	if a == b {
+		return true
+	}
+	if a < utf8.RuneSelf && b < utf8.RuneSelf {
+		if a >= 'A' && a <= 'Z' {
+			a += 'a' - 'A'
+		}
+		if b >= 'A' && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		return a == b
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenSpans returns the spans of the runs of ASCII letters and digits and
+// of non-ASCII bytes in the supplied string
+func tokenSpans(s string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for x := 0; x <= len(s); x++ {
+		word := x < len(s) && (s[x] >= 0x80 || s[x] >= 'a' && s[x] <= 'z' ||
+			s[x] >= 'A' && s[x] <= 'Z' || s[x] >= '0' && s[x] <= '9')
+		switch {
+		case word && start < 0:
+			start = x
+		case !word && start >= 0:
+			spans = append(spans, [2]int{start, x})
+			start = -1
+		}
+	}
+	return spans
+}
+
+// lowerASCII returns the supplied string with ASCII letters lowercased.
+// Unlike strings.ToLower, the result always has the same length, so byte
+// offsets into it are offsets into the original string.
+func lowerASCII(s string) string {
+	for x := 0; x < len(s); x++ {
+		if s[x] >= 'A' && s[x] <= 'Z' {
+			b := []byte(s)
+			for ; x < len(b); x++ {
+				if b[x] >= 'A' && b[x] <= 'Z' {
+					b[x] += 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
@@ -12,6 +12,15 @@ type Subclass struct {
 	ID string `json:"id"`
 	// Name is the common string name for the subclass
 	Name string `json:"name"`
+	// Comments are the comment lines directly above the subclass. See
+	// Vendor.Comments.
+	Comments []string `json:"comments,omitempty"`
+	// Layer is the index in DB.Layers of the pci-ids DB file that the
+	// subclass's name came from. See Vendor.Layer.
+	Layer int `json:"layer,omitempty"`
+	// Class is the class the subclass belongs to. It is not included in JSON
+	// output, which would otherwise be cyclic.
+	Class *Class `json:"-"`
 	// ProgrammingInterfaces contains any programming interfaces this subclass
 	// might have
 	ProgrammingInterfaces []*ProgrammingInterface `json:"programming_interfaces"`
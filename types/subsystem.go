@@ -0,0 +1,69 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package types
+
+import "encoding/json"
+
+// Subsystem is a "subdevice" or "subsystem" of a PCI product: the same PCI
+// device as built into a particular board, identified by the subsystem
+// vendor (subvendor) and subsystem device (subdevice) IDs.
+type Subsystem struct {
+	// SubvendorID is the hex-encoded PCI_ID of the subsystem's vendor, which
+	// may differ from the vendor of the parent product
+	SubvendorID string `json:"subvendor_id"`
+	// NumericSubvendorID is the numeric form of SubvendorID
+	NumericSubvendorID VendorID `json:"-"`
+	// SubdeviceID is the hex-encoded PCI_ID of the subsystem
+	SubdeviceID string `json:"subdevice_id"`
+	// NumericSubdeviceID is the numeric form of SubdeviceID
+	NumericSubdeviceID DeviceID `json:"-"`
+	// Name is the common string name of the subsystem
+	Name string `json:"name"`
+	// Comments are the comment lines directly above the subsystem. See
+	// Vendor.Comments.
+	Comments []string `json:"comments,omitempty"`
+	// Layer is the index in DB.Layers of the pci-ids DB file that the
+	// subsystem's name came from. See Vendor.Layer.
+	Layer int `json:"layer,omitempty"`
+	// Product is the product that the subsystem is a subsystem of
+	Product *Product `json:"-"`
+	// Subvendor is the vendor with SubvendorID, or nil if the DB doesn't
+	// contain it
+	Subvendor *Vendor `json:"-"`
+
+	// VendorID is the same as SubvendorID.
+	//
+	// Deprecated: Use SubvendorID. VendorID is kept, and included in JSON
+	// output as "vendor_id", for consumers written when subsystems were
+	// Products.
+	VendorID string `json:"vendor_id"`
+	// ID is the same as SubdeviceID.
+	//
+	// Deprecated: Use SubdeviceID. ID is kept, and included in JSON output
+	// as "id", for consumers written when subsystems were Products.
+	ID string `json:"id"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting subsystems encoded
+// either with "subvendor_id" and "subdevice_id" or, as they were when
+// subsystems were Products, with only "vendor_id" and "id".
+func (s *Subsystem) UnmarshalJSON(data []byte) error {
+	type subsystem Subsystem
+	if err := json.Unmarshal(data, (*subsystem)(s)); err != nil {
+		return err
+	}
+	if s.SubvendorID == "" {
+		s.SubvendorID = s.VendorID
+	}
+	if s.SubdeviceID == "" {
+		s.SubdeviceID = s.ID
+	}
+	s.VendorID, s.ID = s.SubvendorID, s.SubdeviceID
+	s.NumericSubvendorID, _ = ParseVendorID(s.SubvendorID)
+	s.NumericSubdeviceID, _ = ParseDeviceID(s.SubdeviceID)
+	return nil
+}
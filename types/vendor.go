@@ -10,8 +10,19 @@ package types
 type Vendor struct {
 	// IS is the hex-encoded PCI_ID for the vendor
 	ID string `json:"id"`
+	// NumericID is the numeric form of ID
+	NumericID VendorID `json:"-"`
 	// Name is the common string name of the vendor
 	Name string `json:"name"`
+	// Comments are the comment lines directly above the vendor in the
+	// pci-ids DB file, such as notes about deprecated or reused IDs, without
+	// the leading "# ". They are only loaded when requested with
+	// WithComments.
+	Comments []string `json:"comments,omitempty"`
+	// Layer is the index in DB.Layers of the pci-ids DB file that the
+	// vendor's name came from. It is 0, the base DB file, unless an overlay
+	// added or renamed the vendor.
+	Layer int `json:"layer,omitempty"`
 	// Products contains all top-level devices for the vendor
 	Products []*Product `json:"products"`
 }